@@ -0,0 +1,69 @@
+package artifact
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DEADLINE_DATE_FORMAT = "2006-01-02"
+)
+
+// Deadline is the day a stage has to be bumped by. DaysLeft turns negative
+// once the day has passed; a bumped stage is never overdue.
+type Deadline struct {
+	Stage    string `json:"stage"`
+	Date     string `json:"date"`
+	DaysLeft int    `json:"days_left"`
+	Overdue  bool   `json:"overdue"`
+}
+
+// Summary describes the days left or overdue.
+func (d Deadline) Summary() string {
+	switch {
+	case d.DaysLeft < -1:
+		return fmt.Sprintf("%d days overdue", -d.DaysLeft)
+	case d.DaysLeft == -1:
+		return "1 day overdue"
+	case d.DaysLeft == 0:
+		return "due today"
+	case d.DaysLeft == 1:
+		return "1 day left"
+	}
+	return fmt.Sprintf("%d days left", d.DaysLeft)
+}
+
+// ApplyDeadlines returns copies of the rows with the deadline of every stage
+// listed in deadlines. The deadline of a row is the earliest one of its
+// stages that are not bumped yet.
+func ApplyDeadlines(rows []Row, deadlines map[string]time.Time, now time.Time) []Row {
+	if len(deadlines) == 0 {
+		return rows
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	applied := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		row.Stages = append([]Stage(nil), row.Stages...)
+		row.Deadline = nil
+		for i, stage := range row.Stages {
+			date, ok := deadlines[stage.Name]
+			if !ok {
+				continue
+			}
+			daysLeft := int(date.Sub(today).Hours() / 24)
+			deadline := &Deadline{
+				Stage:    stage.Name,
+				Date:     date.Format(DEADLINE_DATE_FORMAT),
+				DaysLeft: daysLeft,
+				Overdue:  !stage.Bumped && daysLeft < 0,
+			}
+			row.Stages[i].Deadline = deadline
+			if !stage.Bumped && (row.Deadline == nil || deadline.DaysLeft < row.Deadline.DaysLeft) {
+				row.Deadline = deadline
+			}
+		}
+		row.Overdue = row.Deadline != nil && row.Deadline.Overdue
+		applied = append(applied, row)
+	}
+	return applied
+}
@@ -0,0 +1 @@
+package artifact // import "github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
@@ -0,0 +1,58 @@
+package artifact
+
+import (
+	"sync"
+)
+
+// Provider is implemented by every kind of tracked artifact. Kind is a
+// stable identifier used in URLs, metrics and the JSON API, Title is shown
+// to people.
+type Provider interface {
+	Kind() string
+	Title() string
+	Rows(targetVersion string) []Row
+}
+
+type registry struct {
+	providers []Provider
+	mux       sync.Mutex
+}
+
+func NewRegistry() *registry {
+	return &registry{}
+}
+
+func (r *registry) Register(provider Provider) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.providers = append(r.providers, provider)
+}
+
+// Providers returns the providers in registration order.
+func (r *registry) Providers() []Provider {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return append([]Provider(nil), r.providers...)
+}
+
+func (r *registry) Get(kind string) (Provider, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for _, provider := range r.providers {
+		if provider.Kind() == kind {
+			return provider, true
+		}
+	}
+	return nil, false
+}
+
+// Rows collects the rows of every provider, or of one kind when kind is set.
+func (r *registry) Rows(targetVersion string, kind string) []Row {
+	var rows []Row
+	for _, provider := range r.Providers() {
+		if kind == "" || provider.Kind() == kind {
+			rows = append(rows, provider.Rows(targetVersion)...)
+		}
+	}
+	return rows
+}
@@ -0,0 +1,123 @@
+package artifact
+
+const (
+	STATUS_BUMPED      = "bumped"
+	STATUS_IN_PROGRESS = "in-progress"
+	STATUS_BEHIND      = "behind"
+
+	STAGE_DEVELOP  = "develop"
+	STAGE_RELEASED = "released"
+)
+
+// CellError describes why a value could not be refreshed. When StaleFor is
+// set the value shown is the last good one, fetched that long ago.
+type CellError struct {
+	Kind        string `json:"kind"`
+	Explanation string `json:"explanation"`
+	Message     string `json:"message"`
+	StaleFor    string `json:"stale_for,omitempty"`
+}
+
+type Link struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Stage is one step an artifact goes through on its way to users, such as
+// develop, a release or a product tile. Tile stages are only shown to
+// authorized users. URL points at what the version was read from, such as a
+// branch, a tag or a Kilnfile change. Artifacts built for several platforms
+// have one stage per platform. NotVendored marks golang versions read from a
+// blob instead of a vendored golang package.
+type Stage struct {
+	Name        string     `json:"name"`
+	Platform    string     `json:"platform,omitempty"`
+	Version     string     `json:"version,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	Bumped      bool       `json:"bumped"`
+	Tile        bool       `json:"tile,omitempty"`
+	NotVendored bool       `json:"not_vendored,omitempty"`
+	Error       *CellError `json:"error,omitempty"`
+	Deadline    *Deadline  `json:"deadline,omitempty"`
+}
+
+// Label names the stage and its platform, if any.
+func (s Stage) Label() string {
+	if s.Platform == "" {
+		return s.Name
+	}
+	return s.Name + " (" + s.Platform + ")"
+}
+
+// Row is the view of one tracked artifact that is shared by all kinds.
+type Row struct {
+	Kind      string  `json:"kind"`
+	Name      string  `json:"name"`
+	URL       string  `json:"url"`
+	Team      string  `json:"team,omitempty"`
+	Links     []Link  `json:"links,omitempty"`
+	Stages    []Stage `json:"stages"`
+	Status    string  `json:"status"`
+	AllBumped bool    `json:"all_bumped"`
+	// Warnings point out problems that do not block the bump, such as a
+	// go.mod asking for a newer golang than the release packages.
+	Warnings []string `json:"warnings,omitempty"`
+	// Deadline and Overdue are only set while a campaign for the target
+	// has deadlines, see ApplyDeadlines.
+	Deadline *Deadline `json:"deadline,omitempty"`
+	Overdue  bool      `json:"overdue,omitempty"`
+}
+
+// Report is the body of the JSON API.
+type Report struct {
+	Target string `json:"target"`
+	Rows   []Row  `json:"rows"`
+}
+
+// Status summarizes the stages: bumped when the artifact is done, in
+// progress when at least one stage is bumped and behind otherwise.
+func Status(allBumped bool, stages []Stage) string {
+	if allBumped {
+		return STATUS_BUMPED
+	}
+	for _, stage := range stages {
+		if stage.Bumped {
+			return STATUS_IN_PROGRESS
+		}
+	}
+	return STATUS_BEHIND
+}
+
+// WithoutTiles returns a copy of the row without tile stages. AllBumped and
+// Status are recomputed from the remaining stages so that they do not reveal
+// the tile stages either.
+func (r Row) WithoutTiles() Row {
+	var stages []Stage
+	removed := false
+	for _, stage := range r.Stages {
+		if stage.Tile {
+			removed = true
+			continue
+		}
+		stages = append(stages, stage)
+	}
+	r.Stages = stages
+	if removed {
+		r.AllBumped = len(stages) > 0
+		for _, stage := range stages {
+			r.AllBumped = r.AllBumped && stage.Bumped
+		}
+		r.Status = Status(r.AllBumped, stages)
+	}
+	return r
+}
+
+// Stage returns the stage with the given name.
+func (r Row) Stage(name string) (Stage, bool) {
+	for _, stage := range r.Stages {
+		if stage.Name == name {
+			return stage, true
+		}
+	}
+	return Stage{}, false
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+)
+
+const (
+	LOGIN_PATH    = "/auth/login"
+	CALLBACK_PATH = "/auth/callback"
+	LOGOUT_PATH   = "/auth/logout"
+)
+
+// authenticator identifies the caller of a request. It returns nil when the
+// request carries no credentials it understands.
+type authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type auth struct {
+	enabled        bool
+	allowedGroups  []string
+	authenticators []authenticator
+	oidc           *oidcProvider
+}
+
+// New builds the configured authenticators. Without configuration every
+// caller is authorized, matching the behaviour of a private deployment.
+func New(ctx context.Context, cfg *config.Auth) (*auth, error) {
+	if cfg == nil {
+		return &auth{}, nil
+	}
+
+	a := &auth{
+		enabled:       true,
+		allowedGroups: cfg.AllowedGroups,
+	}
+	if len(cfg.APITokens) > 0 {
+		tokens, err := newTokenAuthenticator(cfg.APITokens)
+		if err != nil {
+			return nil, err
+		}
+		a.authenticators = append(a.authenticators, tokens)
+	}
+	if cfg.OIDC != nil {
+		sessionSecret := os.Getenv(cfg.SessionSecretEnv)
+		if sessionSecret == "" {
+			return nil, fmt.Errorf("session secret env %q is not set", cfg.SessionSecretEnv)
+		}
+		oidc, err := newOIDCProvider(ctx, *cfg.OIDC, newSessions([]byte(sessionSecret)))
+		if err != nil {
+			return nil, err
+		}
+		a.oidc = oidc
+		a.authenticators = append(a.authenticators, oidc)
+	}
+	if len(a.authenticators) == 0 {
+		return nil, errors.New("auth is configured without oidc or api tokens")
+	}
+	return a, nil
+}
+
+func (a *auth) Enabled() bool {
+	return a.enabled
+}
+
+// LoginURL is empty when interactive login is not available.
+func (a *auth) LoginURL() string {
+	if a.oidc == nil {
+		return ""
+	}
+	return LOGIN_PATH
+}
+
+func (a *auth) RegisterHandlers(mux *http.ServeMux) {
+	if a.oidc == nil {
+		return
+	}
+	mux.HandleFunc(LOGIN_PATH, a.oidc.Login)
+	mux.HandleFunc(CALLBACK_PATH, a.oidc.Callback)
+	mux.HandleFunc(LOGOUT_PATH, a.oidc.Logout)
+}
+
+// Middleware attaches the caller's Principal to the request context.
+// Requests with invalid credentials are rejected; anonymous requests pass
+// through with an unauthorized Principal.
+func (a *auth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.enabled {
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), Principal{Authorized: true})))
+			return
+		}
+
+		principal := Principal{}
+		for _, authenticator := range a.authenticators {
+			p, err := authenticator.Authenticate(r)
+			if err != nil {
+				log.Printf("failed to authenticate request: %s", err.Error())
+				http.Error(w, "invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			if p != nil {
+				principal = *p
+				break
+			}
+		}
+		principal.Authorized = principal.Authenticated() && authorized(principal.Groups, a.allowedGroups)
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+	})
+}
+
+// RequireAuthorized rejects callers that may not see restricted data.
+func RequireAuthorized(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := FromRequest(r)
+		if !principal.Authorized {
+			if principal.Authenticated() {
+				http.Error(w, "forbidden", http.StatusForbidden)
+			} else {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
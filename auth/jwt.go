@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CLOCK_SKEW = time.Minute
+	// JWKS_REFETCH_INTERVAL limits how often tokens with unknown key ids,
+	// which anyone can send, make the key set be refetched.
+	JWKS_REFETCH_INTERVAL = time.Minute
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// keySet verifies RS256 signed tokens with keys from a JWKS endpoint. Keys
+// are refetched when a token names an unknown key id, at most once per
+// JWKS_REFETCH_INTERVAL. Unknown key ids are remembered as misses for as long.
+type keySet struct {
+	jwksURL    string
+	httpClient *http.Client
+	keys       map[string]*rsa.PublicKey
+	misses     map[string]time.Time
+	lastFetch  time.Time
+	mux        sync.Mutex
+	ctx        context.Context
+}
+
+func newKeySet(ctx context.Context, jwksURL string, httpClient *http.Client) *keySet {
+	return &keySet{
+		jwksURL:    jwksURL,
+		httpClient: httpClient,
+		keys:       map[string]*rsa.PublicKey{},
+		misses:     map[string]time.Time{},
+		ctx:        ctx,
+	}
+}
+
+// verify checks the signature of a compact JWT and returns its claims.
+func (k *keySet) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed jwt")
+	}
+
+	var header jwtHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported jwt algorithm: %s", header.Alg)
+	}
+	key, err := k.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// key returns the key with the given id. The key set is fetched without
+// holding the lock, so verifying tokens with known keys never waits on the
+// JWKS endpoint.
+func (k *keySet) key(kid string) (*rsa.PublicKey, error) {
+	now := time.Now()
+	k.mux.Lock()
+	if key, ok := k.keys[kid]; ok {
+		k.mux.Unlock()
+		return key, nil
+	}
+	missedAt, missed := k.misses[kid]
+	if (missed && now.Sub(missedAt) < JWKS_REFETCH_INTERVAL) || now.Sub(k.lastFetch) < JWKS_REFETCH_INTERVAL {
+		k.mux.Unlock()
+		return nil, fmt.Errorf("unknown jwt key id: %s", kid)
+	}
+	k.lastFetch = now
+	k.mux.Unlock()
+
+	keys, err := k.fetch()
+
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for keyID, key := range keys {
+		k.keys[keyID] = key
+	}
+	k.misses = map[string]time.Time{}
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+	k.misses[kid] = now
+	return nil, fmt.Errorf("unknown jwt key id: %s", kid)
+}
+
+func (k *keySet) fetch() (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(k.ctx, http.MethodGet, k.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := k.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned %d", res.StatusCode)
+	}
+
+	var jwks jsonWebKeySet
+	err = json.NewDecoder(res.Body).Decode(&jwks)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, v)
+}
+
+// validateClaims checks the registered claims of an ID token.
+func validateClaims(claims map[string]interface{}, issuer string, audience string, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != issuer {
+		return fmt.Errorf("unexpected token issuer: %s", iss)
+	}
+	if !hasAudience(claims["aud"], audience) {
+		return errors.New("token is not issued for this client")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(CLOCK_SKEW)) {
+		return errors.New("token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(CLOCK_SKEW).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token is not valid yet")
+	}
+	return nil
+}
+
+func hasAudience(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func stringsClaim(claims map[string]interface{}, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
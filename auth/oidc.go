@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+)
+
+const (
+	STATE_COOKIE         = "golang_bump_oidc_state"
+	STATE_LIFETIME       = 10 * time.Minute
+	DEFAULT_GROUPS_CLAIM = "groups"
+)
+
+var (
+	DEFAULT_SCOPES = []string{"openid", "profile", "groups"}
+)
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Redirect string `json:"redirect"`
+}
+
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+// oidcProvider implements the authorization code flow against any OpenID
+// Connect issuer, including a local mock issuer. It also accepts ID tokens
+// as bearer tokens.
+type oidcProvider struct {
+	config       config.OIDC
+	clientSecret string
+	discovery    oidcDiscovery
+	keys         *keySet
+	sessions     *sessions
+	httpClient   *http.Client
+	ctx          context.Context
+}
+
+func newOIDCProvider(ctx context.Context, cfg config.OIDC, sessions *sessions) (*oidcProvider, error) {
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = DEFAULT_GROUPS_CLAIM
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = DEFAULT_SCOPES
+	}
+	p := &oidcProvider{
+		config:       cfg,
+		clientSecret: os.Getenv(cfg.ClientSecretEnv),
+		sessions:     sessions,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		ctx:          ctx,
+	}
+
+	discoveryURL := strings.TrimRight(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	err := p.getJSON(discoveryURL, &p.discovery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover oidc issuer: %s", err.Error())
+	}
+	if p.discovery.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery returned issuer %s, expected %s", p.discovery.Issuer, cfg.Issuer)
+	}
+	p.keys = newKeySet(ctx, p.discovery.JWKSURI, p.httpClient)
+	return p, nil
+}
+
+func (p *oidcProvider) Authenticate(r *http.Request) (*Principal, error) {
+	if token := bearerToken(r); token != "" && isJWT(token) {
+		claims, err := p.verifyIDToken(token, "")
+		if err != nil {
+			return nil, err
+		}
+		return p.principal(claims), nil
+	}
+
+	cookie, err := r.Cookie(SESSION_COOKIE)
+	if err != nil {
+		return nil, nil
+	}
+	var s session
+	err = p.sessions.decode(cookie.Value, &s)
+	if err != nil || time.Now().After(s.Expires) {
+		return nil, nil
+	}
+	return &Principal{Subject: s.Subject, Groups: s.Groups}, nil
+}
+
+func (p *oidcProvider) Login(w http.ResponseWriter, r *http.Request) {
+	state := oidcState{
+		State:    randomString(),
+		Nonce:    randomString(),
+		Redirect: safeRedirect(r.URL.Query().Get("redirect")),
+	}
+	err := p.sessions.setCookie(w, r, STATE_COOKIE, state, STATE_LIFETIME)
+	if err != nil {
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.config.ClientID},
+		"redirect_uri":  {p.config.RedirectURL},
+		"scope":         {strings.Join(p.config.Scopes, " ")},
+		"state":         {state.State},
+		"nonce":         {state.Nonce},
+	}
+	http.Redirect(w, r, p.discovery.AuthorizationEndpoint+"?"+query.Encode(), http.StatusFound)
+}
+
+func (p *oidcProvider) Callback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(STATE_COOKIE)
+	if err != nil {
+		http.Error(w, "login expired", http.StatusBadRequest)
+		return
+	}
+	clearCookie(w, STATE_COOKIE)
+	var state oidcState
+	err = p.sessions.decode(cookie.Value, &state)
+	if err != nil || state.State != r.URL.Query().Get("state") {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		http.Error(w, "login failed: "+errParam, http.StatusUnauthorized)
+		return
+	}
+
+	idToken, err := p.exchange(r.URL.Query().Get("code"))
+	if err != nil {
+		log.Printf("failed to exchange oidc code: %s", err.Error())
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	claims, err := p.verifyIDToken(idToken, state.Nonce)
+	if err != nil {
+		log.Printf("failed to verify oidc id token: %s", err.Error())
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	principal := p.principal(claims)
+	err = p.sessions.setCookie(w, r, SESSION_COOKIE, session{
+		Subject: principal.Subject,
+		Groups:  principal.Groups,
+		Expires: time.Now().Add(SESSION_LIFETIME),
+	}, SESSION_LIFETIME)
+	if err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, state.Redirect, http.StatusFound)
+}
+
+func (p *oidcProvider) Logout(w http.ResponseWriter, r *http.Request) {
+	clearCookie(w, SESSION_COOKIE)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (p *oidcProvider) exchange(code string) (string, error) {
+	if code == "" {
+		return "", errors.New("no authorization code")
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.clientSecret},
+	}
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d", res.StatusCode)
+	}
+
+	var token tokenResponse
+	err = json.NewDecoder(res.Body).Decode(&token)
+	if err != nil {
+		return "", err
+	}
+	if token.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return token.IDToken, nil
+}
+
+func (p *oidcProvider) verifyIDToken(token string, nonce string) (map[string]interface{}, error) {
+	claims, err := p.keys.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	err = validateClaims(claims, p.discovery.Issuer, p.config.ClientID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if nonce != "" {
+		if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+			return nil, errors.New("token nonce does not match")
+		}
+	}
+	return claims, nil
+}
+
+func (p *oidcProvider) principal(claims map[string]interface{}) *Principal {
+	subject, _ := claims["sub"].(string)
+	if email, ok := claims["email"].(string); ok && email != "" {
+		subject = email
+	}
+	return &Principal{
+		Subject: subject,
+		Groups:  stringsClaim(claims, p.config.GroupsClaim),
+	}
+}
+
+func (p *oidcProvider) getJSON(url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// safeRedirect only allows local paths, to avoid open redirects after login.
+func safeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") {
+		return "/"
+	}
+	return redirect
+}
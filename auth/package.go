@@ -0,0 +1 @@
+package auth // import "github.com/cloudfoundry-incubator/golang-bump-progress/auth"
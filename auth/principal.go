@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey struct{}
+
+// Principal is the caller of a request. Anonymous callers have an empty
+// Subject. Authorized is set when the caller may see restricted data, such as
+// tile versions.
+type Principal struct {
+	Subject    string
+	Groups     []string
+	Authorized bool
+}
+
+func (p Principal) Authenticated() bool {
+	return p.Subject != ""
+}
+
+func FromContext(ctx context.Context) Principal {
+	principal, _ := ctx.Value(contextKey{}).(Principal)
+	return principal
+}
+
+func FromRequest(r *http.Request) Principal {
+	return FromContext(r.Context())
+}
+
+func withPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+func authorized(groups []string, allowedGroups []string) bool {
+	if len(allowedGroups) == 0 {
+		return true
+	}
+	for _, group := range groups {
+		for _, allowed := range allowedGroups {
+			if group == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
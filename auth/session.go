@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	SESSION_COOKIE   = "golang_bump_session"
+	SESSION_LIFETIME = 12 * time.Hour
+)
+
+type session struct {
+	Subject string    `json:"sub"`
+	Groups  []string  `json:"groups"`
+	Expires time.Time `json:"exp"`
+}
+
+// sessions signs session cookies with HMAC-SHA256, so no server-side state
+// is needed.
+type sessions struct {
+	secret []byte
+}
+
+func newSessions(secret []byte) *sessions {
+	return &sessions{
+		secret: secret,
+	}
+}
+
+func (s *sessions) encode(value interface{}) (string, error) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded)), nil
+}
+
+func (s *sessions) decode(cookie string, value interface{}) error {
+	encoded, signature, found := strings.Cut(cookie, ".")
+	if !found {
+		return errors.New("malformed cookie")
+	}
+	decodedSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(decodedSignature, s.sign(encoded)) {
+		return errors.New("invalid cookie signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, value)
+}
+
+func (s *sessions) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+func (s *sessions) setCookie(w http.ResponseWriter, r *http.Request, name string, value interface{}, maxAge time.Duration) error {
+	encoded, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    encoded,
+		Path:     "/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:   name,
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+)
+
+type apiToken struct {
+	name   string
+	digest [sha256.Size]byte
+	groups []string
+}
+
+// tokenAuthenticator accepts static bearer tokens read from the environment,
+// for the JSON and CLI clients.
+type tokenAuthenticator struct {
+	tokens []apiToken
+}
+
+func newTokenAuthenticator(cfg []config.APIToken) (*tokenAuthenticator, error) {
+	t := &tokenAuthenticator{}
+	for _, token := range cfg {
+		value := os.Getenv(token.TokenEnv)
+		if value == "" {
+			return nil, fmt.Errorf("api token env %q for %s is not set", token.TokenEnv, token.Name)
+		}
+		t.tokens = append(t.tokens, apiToken{
+			name:   token.Name,
+			digest: sha256.Sum256([]byte(value)),
+			groups: token.Groups,
+		})
+	}
+	return t, nil
+}
+
+func (t *tokenAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearerToken(r)
+	if token == "" || isJWT(token) {
+		return nil, nil
+	}
+	digest := sha256.Sum256([]byte(token))
+	for _, apiToken := range t.tokens {
+		if subtle.ConstantTimeCompare(digest[:], apiToken.digest[:]) == 1 {
+			return &Principal{
+				Subject: "token:" + apiToken.name,
+				Groups:  apiToken.groups,
+			}, nil
+		}
+	}
+	return nil, errors.New("unknown api token")
+}
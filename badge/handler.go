@@ -0,0 +1,172 @@
+package badge
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/dataprovider"
+)
+
+const (
+	PATH_PREFIX = "/badges/"
+	LABEL       = "go"
+
+	COLOR_BUMPED      = "#4c1"
+	COLOR_IN_PROGRESS = "#dfb317"
+	COLOR_BEHIND      = "#e05d44"
+	COLOR_UNKNOWN     = "#9f9f9f"
+
+	// Verdana at 11px averages about 7px per character.
+	CHAR_WIDTH = 7
+	PADDING    = 10
+)
+
+var (
+	statusColors = map[string]string{
+		artifact.STATUS_BUMPED:      COLOR_BUMPED,
+		artifact.STATUS_IN_PROGRESS: COLOR_IN_PROGRESS,
+		artifact.STATUS_BEHIND:      COLOR_BEHIND,
+	}
+)
+
+type providerRegistry interface {
+	Get(kind string) (artifact.Provider, bool)
+}
+
+type badge struct {
+	Label        string
+	Message      string
+	Status       string
+	Color        string
+	LabelWidth   int
+	MessageWidth int
+	Width        int
+	LabelX       float64
+	MessageX     float64
+}
+
+// handler serves /badges/<kind>s/<name>.svg, for example
+// /badges/releases/diego-release.svg, from the cached rows of the provider.
+// Badges are public, so tile stages are never shown and the color comes from
+// the status of the remaining stages.
+type handler struct {
+	registry providerRegistry
+	tmpl     *template.Template
+	target   func() string
+}
+
+func NewHandler(registry providerRegistry, tmpl *template.Template, target func() string) *handler {
+	return &handler{
+		registry: registry,
+		tmpl:     tmpl,
+		target:   target,
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, PATH_PREFIX)
+	separator := strings.LastIndex(path, "/")
+	if separator < 0 || !strings.HasSuffix(path, ".svg") {
+		http.NotFound(w, r)
+		return
+	}
+	provider, ok := h.provider(path[:separator])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimSuffix(path[separator+1:], ".svg")
+	row, ok := findRow(provider.Rows(h.target()), name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var buf bytes.Buffer
+	err := h.tmpl.Execute(&buf, newBadge(row.WithoutTiles()))
+	if err != nil {
+		log.Printf("failed to render badge for %s: %s", name, err.Error())
+		http.Error(w, "failed to render badge", http.StatusInternalServerError)
+		return
+	}
+
+	// GitHub's image proxy honors max-age and revalidates with the ETag.
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(dataprovider.FETCH_INTERVAL.Seconds())))
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	buf.WriteTo(w)
+}
+
+// provider accepts both the plural used in badge URLs and the kind itself.
+func (h *handler) provider(kindPath string) (artifact.Provider, bool) {
+	if provider, ok := h.registry.Get(kindPath); ok {
+		return provider, true
+	}
+	first, rest, found := strings.Cut(kindPath, "/")
+	kind := strings.TrimSuffix(first, "s")
+	if found {
+		kind += "/" + rest
+	}
+	return h.registry.Get(kind)
+}
+
+func findRow(rows []artifact.Row, name string) (artifact.Row, bool) {
+	for _, row := range rows {
+		if row.Name == name {
+			return row, true
+		}
+	}
+	return artifact.Row{}, false
+}
+
+// newBadge shows the released Go version, or the one on develop for
+// artifacts that are not released yet.
+func newBadge(row artifact.Row) badge {
+	message := ""
+	for _, stageName := range []string{artifact.STAGE_RELEASED, artifact.STAGE_DEVELOP} {
+		if stage, ok := row.Stage(stageName); ok && stage.Version != "" {
+			message = stage.Version
+			break
+		}
+	}
+	if message == "" {
+		for _, stage := range row.Stages {
+			if stage.Version != "" {
+				message = stage.Version
+				break
+			}
+		}
+	}
+	color, ok := statusColors[row.Status]
+	if message == "" || !ok {
+		message = "unknown"
+		color = COLOR_UNKNOWN
+	}
+
+	labelWidth := len(LABEL)*CHAR_WIDTH + PADDING
+	messageWidth := len(message)*CHAR_WIDTH + PADDING
+	return badge{
+		Label:        LABEL,
+		Message:      message,
+		Status:       row.Status,
+		Color:        color,
+		LabelWidth:   labelWidth,
+		MessageWidth: messageWidth,
+		Width:        labelWidth + messageWidth,
+		LabelX:       float64(labelWidth) / 2,
+		MessageX:     float64(labelWidth) + float64(messageWidth)/2,
+	}
+}
@@ -0,0 +1 @@
+package badge // import "github.com/cloudfoundry-incubator/golang-bump-progress/badge"
@@ -0,0 +1,82 @@
+package ci
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	CONCOURSE_BUILDS_LIMIT = 50
+)
+
+type ConcourseBuild struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	JobName   string `json:"job_name"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+}
+
+type ConcourseJob struct {
+	Name          string          `json:"name"`
+	FinishedBuild *ConcourseBuild `json:"finished_build"`
+	NextBuild     *ConcourseBuild `json:"next_build"`
+}
+
+type ConcourseClient interface {
+	ListJobs(team string, pipeline string) ([]ConcourseJob, error)
+	ListJobBuilds(team string, pipeline string, job string, limit int) ([]ConcourseBuild, error)
+}
+
+type concourseClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+func NewConcourseClient(baseURL string, token string) *concourseClient {
+	return &concourseClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *concourseClient) ListJobs(team string, pipeline string) ([]ConcourseJob, error) {
+	var jobs []ConcourseJob
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/jobs", url.PathEscape(team), url.PathEscape(pipeline))
+	err := c.get(path, &jobs)
+	return jobs, err
+}
+
+func (c *concourseClient) ListJobBuilds(team string, pipeline string, job string, limit int) ([]ConcourseBuild, error) {
+	var builds []ConcourseBuild
+	path := fmt.Sprintf("/api/v1/teams/%s/pipelines/%s/jobs/%s/builds?limit=%d", url.PathEscape(team), url.PathEscape(pipeline), url.PathEscape(job), limit)
+	err := c.get(path, &builds)
+	return builds, err
+}
+
+func (c *concourseClient) get(path string, result interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("concourse returned %d for %s", res.StatusCode, path)
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
@@ -0,0 +1,120 @@
+package ci
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+)
+
+type PipelineStatus struct {
+	LatestBuildStatus    string
+	LatestBuildJob       string
+	BumpJob              string
+	BumpJobStatus        string
+	BumpJobRunning       bool
+	BumpJobLastSucceeded time.Time
+}
+
+func (s PipelineStatus) BumpJobFailed() bool {
+	switch s.BumpJobStatus {
+	case STATUS_FAILED, STATUS_ERRORED, STATUS_ABORTED:
+		return true
+	}
+	return false
+}
+
+type concourseProvider struct {
+	baseURL string
+	client  ConcourseClient
+}
+
+func NewConcourseProvider(baseURL string, client ConcourseClient) *concourseProvider {
+	return &concourseProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+	}
+}
+
+func (p *concourseProvider) GetStatus(cfg config.CI) (Status, error) {
+	pipelineURL := fmt.Sprintf("%s/teams/%s/pipelines/%s", p.baseURL, cfg.Team, cfg.Pipeline)
+	status := Status{
+		Provider: config.CI_PROVIDER_CONCOURSE,
+		URL:      pipelineURL,
+	}
+
+	pipelineStatus, err := p.GetPipelineStatus(cfg.Team, cfg.Pipeline, cfg.Job)
+	status.State = pipelineStatus.LatestBuildStatus
+	if pipelineStatus.BumpJob != "" {
+		status.BumpRunURL = fmt.Sprintf("%s/jobs/%s", pipelineURL, pipelineStatus.BumpJob)
+		status.BumpRunStatus = pipelineStatus.BumpJobStatus
+		if pipelineStatus.BumpJobRunning {
+			status.BumpRunStatus = STATUS_STARTED
+		}
+		status.BumpRunTime = pipelineStatus.BumpJobLastSucceeded
+	}
+	return status, err
+}
+
+// GetPipelineStatus reports the most recently finished build in the pipeline
+// and the state of its golang bump job. When bumpJob is empty the first job
+// with "golang" in its name is used.
+func (p *concourseProvider) GetPipelineStatus(team string, pipeline string, bumpJob string) (PipelineStatus, error) {
+	status := PipelineStatus{
+		LatestBuildStatus: STATUS_UNKNOWN,
+		BumpJobStatus:     STATUS_UNKNOWN,
+	}
+	jobs, err := p.client.ListJobs(team, pipeline)
+	if err != nil {
+		return status, err
+	}
+
+	var latestEndTime int64
+	var job *ConcourseJob
+	for i, j := range jobs {
+		if j.FinishedBuild != nil && j.FinishedBuild.EndTime > latestEndTime {
+			latestEndTime = j.FinishedBuild.EndTime
+			status.LatestBuildStatus = j.FinishedBuild.Status
+			status.LatestBuildJob = j.Name
+		}
+		if job == nil && isBumpJob(j.Name, bumpJob) {
+			job = &jobs[i]
+		}
+	}
+
+	if job == nil {
+		return status, fmt.Errorf("failed to find golang bump job in %s/%s", team, pipeline)
+	}
+
+	status.BumpJob = job.Name
+	if job.NextBuild != nil && (job.NextBuild.Status == STATUS_STARTED || job.NextBuild.Status == STATUS_PENDING) {
+		status.BumpJobRunning = true
+	}
+	if job.FinishedBuild != nil {
+		status.BumpJobStatus = job.FinishedBuild.Status
+		if job.FinishedBuild.Status == STATUS_SUCCEEDED {
+			status.BumpJobLastSucceeded = time.Unix(job.FinishedBuild.EndTime, 0)
+			return status, nil
+		}
+	}
+
+	builds, err := p.client.ListJobBuilds(team, pipeline, job.Name, CONCOURSE_BUILDS_LIMIT)
+	if err != nil {
+		return status, err
+	}
+	for _, build := range builds {
+		if build.Status == STATUS_SUCCEEDED {
+			status.BumpJobLastSucceeded = time.Unix(build.EndTime, 0)
+			break
+		}
+	}
+	return status, nil
+}
+
+func isBumpJob(name string, bumpJob string) bool {
+	if bumpJob != "" {
+		return name == bumpJob
+	}
+	return strings.Contains(name, "golang")
+}
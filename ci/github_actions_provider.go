@@ -0,0 +1,98 @@
+package ci
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/google/go-github/v54/github"
+)
+
+const (
+	GITHUB_ACTIONS_RUNS = 50
+)
+
+var (
+	GOLANG_BUMP_RUN_RE = regexp.MustCompile(`(?i)golang|\bgo\s?1\.\d+|\bbump(s|ed)? go\b`)
+)
+
+type githubActionsProvider struct {
+	githubClient *github.Client
+	ctx          context.Context
+}
+
+func NewGithubActionsProvider(ctx context.Context, githubClient *github.Client) *githubActionsProvider {
+	return &githubActionsProvider{
+		githubClient: githubClient,
+		ctx:          ctx,
+	}
+}
+
+// GetStatus reports the latest workflow run and the latest run whose title,
+// branch or head commit mentions a golang bump. BumpRunTime is when the
+// latest successful bump run finished, as for Concourse.
+func (p *githubActionsProvider) GetStatus(cfg config.CI) (Status, error) {
+	status := Status{
+		Provider: config.CI_PROVIDER_GITHUB_ACTIONS,
+		URL:      fmt.Sprintf("https://github.com/%s/%s/actions", cfg.Owner, cfg.Repo),
+		State:    STATUS_UNKNOWN,
+	}
+	opts := &github.ListWorkflowRunsOptions{ListOptions: github.ListOptions{PerPage: GITHUB_ACTIONS_RUNS}}
+
+	var runs *github.WorkflowRuns
+	var err error
+	if cfg.Workflow != "" {
+		status.URL = fmt.Sprintf("%s/workflows/%s", status.URL, cfg.Workflow)
+		runs, _, err = p.githubClient.Actions.ListWorkflowRunsByFileName(p.ctx, cfg.Owner, cfg.Repo, cfg.Workflow, opts)
+	} else {
+		runs, _, err = p.githubClient.Actions.ListRepositoryWorkflowRuns(p.ctx, cfg.Owner, cfg.Repo, opts)
+	}
+	if err != nil {
+		return status, err
+	}
+
+	if len(runs.WorkflowRuns) > 0 {
+		status.State = runState(runs.WorkflowRuns[0])
+	}
+	for _, run := range runs.WorkflowRuns {
+		if !isGolangBumpRun(run) {
+			continue
+		}
+		if status.BumpRunURL == "" {
+			status.BumpRunURL = run.GetHTMLURL()
+			status.BumpRunStatus = runState(run)
+		}
+		if runState(run) == STATUS_SUCCEEDED {
+			status.BumpRunTime = run.GetUpdatedAt().Time
+			break
+		}
+	}
+	return status, nil
+}
+
+func runState(run *github.WorkflowRun) string {
+	switch run.GetStatus() {
+	case "completed":
+		switch run.GetConclusion() {
+		case "success":
+			return STATUS_SUCCEEDED
+		case "failure", "timed_out":
+			return STATUS_FAILED
+		case "cancelled":
+			return STATUS_ABORTED
+		default:
+			return run.GetConclusion()
+		}
+	case "in_progress":
+		return STATUS_STARTED
+	default:
+		return STATUS_PENDING
+	}
+}
+
+func isGolangBumpRun(run *github.WorkflowRun) bool {
+	return GOLANG_BUMP_RUN_RE.MatchString(run.GetDisplayTitle()) ||
+		GOLANG_BUMP_RUN_RE.MatchString(run.GetHeadBranch()) ||
+		GOLANG_BUMP_RUN_RE.MatchString(run.GetHeadCommit().GetMessage())
+}
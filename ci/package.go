@@ -0,0 +1 @@
+package ci // import "github.com/cloudfoundry-incubator/golang-bump-progress/ci"
@@ -0,0 +1,56 @@
+package ci
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+)
+
+const (
+	STATUS_SUCCEEDED = "succeeded"
+	STATUS_FAILED    = "failed"
+	STATUS_ERRORED   = "errored"
+	STATUS_ABORTED   = "aborted"
+	STATUS_STARTED   = "started"
+	STATUS_PENDING   = "pending"
+	STATUS_UNKNOWN   = "unknown"
+)
+
+// Status is the state of a CI setup. BumpRunTime is when the golang bump last
+// succeeded, whatever the state of the latest bump run.
+type Status struct {
+	Provider      string
+	State         string
+	URL           string
+	BumpRunURL    string
+	BumpRunStatus string
+	BumpRunTime   time.Time
+}
+
+type Provider interface {
+	GetStatus(cfg config.CI) (Status, error)
+}
+
+type providers struct {
+	providers map[string]Provider
+}
+
+func NewProviders(providersByName map[string]Provider) *providers {
+	return &providers{
+		providers: providersByName,
+	}
+}
+
+// GetStatus dispatches to the provider configured for the artifact. An
+// artifact without CI configuration gets an empty status.
+func (p *providers) GetStatus(cfg config.CI) (Status, error) {
+	if cfg.Provider == "" {
+		return Status{}, nil
+	}
+	provider, ok := p.providers[cfg.Provider]
+	if !ok {
+		return Status{Provider: cfg.Provider}, fmt.Errorf("unsupported ci provider: %s", cfg.Provider)
+	}
+	return provider.GetStatus(cfg)
+}
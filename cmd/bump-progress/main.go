@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+)
+
+const (
+	EXIT_ALL_BUMPED = 0
+	EXIT_NOT_BUMPED = 1
+	EXIT_ERROR      = 2
+	EXIT_OVERDUE    = 3
+
+	REQUEST_TIMEOUT = 5 * time.Minute
+)
+
+// bump-progress prints the rows of a running dashboard and exits non-zero
+// until every row is bumped, with a distinct code once a campaign deadline
+// has passed. Set BUMP_PROGRESS_TOKEN to one of the dashboard API tokens to
+// include tile stages.
+func main() {
+	serverURL := flag.String("url", "http://localhost:8080", "dashboard URL")
+	target := flag.String("target", "", "target version, defaults to the dashboard's target golang version")
+	kind := flag.String("kind", "", "only report this artifact kind")
+	flag.Parse()
+
+	report, err := fetchReport(*serverURL, *target, *kind, os.Getenv("BUMP_PROGRESS_TOKEN"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to fetch report: %s\n", err.Error())
+		os.Exit(EXIT_ERROR)
+	}
+
+	fmt.Printf("Target: %s\n\n", report.Target)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tSTATUS\tDEADLINE\tSTAGES")
+	allBumped := true
+	overdue := false
+	for _, row := range report.Rows {
+		var stages []string
+		for _, stage := range row.Stages {
+			stages = append(stages, fmt.Sprintf("%s=%s", stage.Label(), stage.Version))
+		}
+		deadline := "-"
+		if row.Deadline != nil {
+			deadline = fmt.Sprintf("%s %s", row.Deadline.Stage, row.Deadline.Summary())
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", row.Kind, row.Name, row.Status, deadline, strings.Join(stages, " "))
+		allBumped = allBumped && row.AllBumped
+		overdue = overdue || row.Overdue
+	}
+	w.Flush()
+
+	if overdue {
+		os.Exit(EXIT_OVERDUE)
+	}
+	if !allBumped {
+		os.Exit(EXIT_NOT_BUMPED)
+	}
+	os.Exit(EXIT_ALL_BUMPED)
+}
+
+func fetchReport(serverURL string, target string, kind string, token string) (artifact.Report, error) {
+	query := url.Values{}
+	if target != "" {
+		query.Set("target", target)
+	}
+	if kind != "" {
+		query.Set("kind", kind)
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(serverURL, "/")+"/api/v1/artifacts?"+query.Encode(), nil)
+	if err != nil {
+		return artifact.Report{}, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: REQUEST_TIMEOUT}
+	res, err := client.Do(req)
+	if err != nil {
+		return artifact.Report{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return artifact.Report{}, fmt.Errorf("%s returned %d", req.URL, res.StatusCode)
+	}
+
+	var report artifact.Report
+	err = json.NewDecoder(res.Body).Decode(&report)
+	if err != nil {
+		return artifact.Report{}, err
+	}
+	return report, nil
+}
@@ -5,26 +5,149 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 )
 
-type Release struct {
-	Name            string `json:"name"`
-	URL             string `json:"url"`
-	Owner           string
-	Repo            string
+const (
+	DEFAULT_DEVELOP_BRANCH            = "develop"
+	DEFAULT_EXTERNAL_PROVIDER_TIMEOUT = time.Minute
+	CAMPAIGN_DATE_FORMAT              = "2006-01-02"
+
+	CI_PROVIDER_CONCOURSE      = "concourse"
+	CI_PROVIDER_GITHUB_ACTIONS = "github-actions"
+)
+
+type CI struct {
+	Provider string `json:"provider"`
+	Team     string `json:"team"`
+	Pipeline string `json:"pipeline"`
+	Job      string `json:"job"`
+	URL      string `json:"url"`
+	Workflow string `json:"workflow"`
+	Owner    string
+	Repo     string
+}
+
+type ReleaseLine struct {
+	Branch     string `json:"branch"`
+	TagPattern string `json:"tag_pattern"`
+}
+
+// ReleasePlatform is one platform of a release that ships a golang package
+// per platform, with the names of the release in that platform's tiles.
+type ReleasePlatform struct {
 	Platform        string `json:"platform"`
 	TasReleaseName  string `json:"tas_release_name"`
 	TaswReleaseName string `json:"tasw_release_name"`
 	IstReleaseName  string `json:"ist_release_name"`
-	CITeam          string `json:"ci_team"`
-	CIPipeline      string `json:"ci_pipeline"`
-	OnlyDevelop     bool   `json:"only_develop"`
+}
+
+type Release struct {
+	Name               string `json:"name"`
+	URL                string `json:"url"`
+	Owner              string
+	Repo               string
+	Platform           string            `json:"platform"`
+	TasReleaseName     string            `json:"tas_release_name"`
+	TaswReleaseName    string            `json:"tasw_release_name"`
+	IstReleaseName     string            `json:"ist_release_name"`
+	CITeam             string            `json:"ci_team"`
+	CIPipeline         string            `json:"ci_pipeline"`
+	CIBumpJob          string            `json:"ci_bump_job"`
+	OnlyDevelop        bool              `json:"only_develop"`
+	BumpPRTitlePattern string            `json:"bump_pr_title_pattern"`
+	CI                 CI                `json:"ci"`
+	DevelopBranch      string            `json:"develop_branch"`
+	TagPattern         string            `json:"tag_pattern"`
+	TagPrefix          string            `json:"tag_prefix"`
+	IncludePrereleases bool              `json:"include_prereleases"`
+	IncludeDrafts      bool              `json:"include_drafts"`
+	ReleaseLines       []ReleaseLine     `json:"release_lines"`
+	Platforms          []ReleasePlatform `json:"platforms"`
+	TagRegexp          *regexp.Regexp    `json:"-"`
+	BumpPRTitleRegexp  *regexp.Regexp    `json:"-"`
+	PlatformIndex      int               `json:"-"`
+	PlatformCount      int               `json:"-"`
+}
+
+// Lines returns the release itself followed by one entry per maintained
+// release line. Release lines track their own branch and tags and are not
+// compared against tiles.
+func (r Release) Lines() []Release {
+	lines := []Release{r}
+	for _, line := range r.ReleaseLines {
+		lineRelease := r
+		lineRelease.Name = fmt.Sprintf("%s (%s)", r.Name, line.Branch)
+		lineRelease.DevelopBranch = line.Branch
+		lineRelease.TagPattern = line.TagPattern
+		lineRelease.TagRegexp = regexp.MustCompile(line.TagPattern)
+		lineRelease.TasReleaseName = ""
+		lineRelease.TaswReleaseName = ""
+		lineRelease.IstReleaseName = ""
+		lineRelease.Platforms = nil
+		for _, platform := range r.Platforms {
+			lineRelease.Platforms = append(lineRelease.Platforms, ReleasePlatform{Platform: platform.Platform})
+		}
+		lineRelease.CI = CI{}
+		lineRelease.ReleaseLines = nil
+		lines = append(lines, lineRelease)
+	}
+	return lines
+}
+
+// PlatformReleases returns one entry per platform of a release that lists
+// platforms, each with its own platform and tile names, or the release
+// itself.
+func (r Release) PlatformReleases() []Release {
+	if len(r.Platforms) == 0 {
+		return []Release{r}
+	}
+	var releases []Release
+	for i, platform := range r.Platforms {
+		platformRelease := r
+		platformRelease.Platform = platform.Platform
+		platformRelease.TasReleaseName = platform.TasReleaseName
+		platformRelease.TaswReleaseName = platform.TaswReleaseName
+		platformRelease.IstReleaseName = platform.IstReleaseName
+		platformRelease.PlatformIndex = i
+		platformRelease.PlatformCount = len(r.Platforms)
+		releases = append(releases, platformRelease)
+	}
+	return releases
+}
+
+// WithoutPlatforms returns the release with the tile names of all its
+// platforms, for what does not depend on the platform, such as Go modules.
+func (r Release) WithoutPlatforms() Release {
+	for _, platform := range r.Platforms {
+		if r.TasReleaseName == "" {
+			r.TasReleaseName = platform.TasReleaseName
+		}
+		if r.TaswReleaseName == "" {
+			r.TaswReleaseName = platform.TaswReleaseName
+		}
+		if r.IstReleaseName == "" {
+			r.IstReleaseName = platform.IstReleaseName
+		}
+	}
+	r.Platforms = nil
+	return r
+}
+
+// MatchesTag reports whether a release tag belongs to this release line.
+func (r Release) MatchesTag(tag string) bool {
+	if r.TagRegexp == nil {
+		return true
+	}
+	return r.TagRegexp.MatchString(tag)
 }
 
 type Image struct {
-	Name string
-	URL  string
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	CI   CI     `json:"ci"`
 }
 
 type Plugin struct {
@@ -32,21 +155,100 @@ type Plugin struct {
 	URL   string `json:"url"`
 	Owner string
 	Repo  string
+	CI    CI `json:"ci"`
 }
 
-type Config struct {
-	CIBaseURL string    `json:"ci_url"`
-	Releases  []Release `json:"releases"`
-	Images    []Image   `json:"images"`
-	Plugins   []Plugin  `json:"plugins"`
+type Buildpack struct {
+	Name               string `json:"name"`
+	URL                string `json:"url"`
+	Owner              string
+	Repo               string
+	ManifestDependency string `json:"manifest_dependency"`
+	CI                 CI     `json:"ci"`
+}
+
+// ModuleTarget is a campaign to bump a Go module dependency to at least
+// Version across all releases.
+type ModuleTarget struct {
+	Module  string `json:"module"`
+	Version string `json:"version"`
+}
+
+// ExternalProvider is an executable that reports rows of its own artifact
+// kind, see the external package for the protocol.
+type ExternalProvider struct {
+	Name          string        `json:"name"`
+	Title         string        `json:"title"`
+	Command       string        `json:"command"`
+	Args          []string      `json:"args"`
+	Timeout       string        `json:"timeout"`
+	ParsedTimeout time.Duration `json:"-"`
+}
+
+// Campaign is a commitment to ship a target golang version by a deadline per
+// stage. Deadlines are keyed by stage name: develop, released or a tile such
+// as TAS.
+type Campaign struct {
+	Target        string               `json:"target"`
+	Start         string               `json:"start"`
+	Deadlines     map[string]string    `json:"deadlines"`
+	StartDate     time.Time            `json:"-"`
+	DeadlineDates map[string]time.Time `json:"-"`
+}
+
+type TrackingIssue struct {
+	URL    string `json:"url"`
+	Label  string `json:"label"`
+	DryRun bool   `json:"dry_run"`
+	Owner  string
+	Repo   string
 }
 
-func (c Config) CIURL(release Release) string {
-	return fmt.Sprintf("%s/teams/%s/pipelines/%s", c.CIBaseURL, release.CITeam, release.CIPipeline)
+type APIToken struct {
+	Name     string   `json:"name"`
+	TokenEnv string   `json:"token_env"`
+	Groups   []string `json:"groups"`
 }
 
-func (c Config) CIBadgeURL(release Release) string {
-	return fmt.Sprintf("%s/api/v1/teams/%s/pipelines/%s/badge", c.CIBaseURL, release.CITeam, release.CIPipeline)
+type OIDC struct {
+	Issuer          string   `json:"issuer"`
+	ClientID        string   `json:"client_id"`
+	ClientSecretEnv string   `json:"client_secret_env"`
+	RedirectURL     string   `json:"redirect_url"`
+	GroupsClaim     string   `json:"groups_claim"`
+	Scopes          []string `json:"scopes"`
+}
+
+type Auth struct {
+	AllowedGroups    []string   `json:"allowed_groups"`
+	SessionSecretEnv string     `json:"session_secret_env"`
+	OIDC             *OIDC      `json:"oidc"`
+	APITokens        []APIToken `json:"api_tokens"`
+}
+
+type Config struct {
+	CIBaseURL          string             `json:"ci_url"`
+	BumpPRTitlePattern string             `json:"bump_pr_title_pattern"`
+	TrackingIssue      *TrackingIssue     `json:"tracking_issue"`
+	Auth               *Auth              `json:"auth"`
+	Releases           []Release          `json:"releases"`
+	Images             []Image            `json:"images"`
+	Plugins            []Plugin           `json:"plugins"`
+	Buildpacks         []Buildpack        `json:"buildpacks"`
+	ModuleTargets      []ModuleTarget     `json:"module_targets"`
+	ExternalProviders  []ExternalProvider `json:"external_providers"`
+	Campaigns          []Campaign         `json:"campaigns"`
+	BumpPRTitleRegexp  *regexp.Regexp     `json:"-"`
+}
+
+// CampaignFor returns the campaign for a target golang version.
+func (c Config) CampaignFor(target string) (Campaign, bool) {
+	for _, campaign := range c.Campaigns {
+		if campaign.Target == target {
+			return campaign, true
+		}
+	}
+	return Campaign{}, false
 }
 
 func LoadConfig(filePath string) (Config, error) {
@@ -59,23 +261,160 @@ func LoadConfig(filePath string) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	if cfg.BumpPRTitlePattern != "" {
+		cfg.BumpPRTitleRegexp, err = regexp.Compile(cfg.BumpPRTitlePattern)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse bump pull request title pattern: %w", err)
+		}
+	}
 	for i, release := range cfg.Releases {
-		url, err := url.Parse(release.URL)
+		cfg.Releases[i].Owner, cfg.Releases[i].Repo, err = parseOwnerRepo(release.URL)
+		if err != nil {
+			return Config{}, err
+		}
+		if release.CI.Provider == "" && release.CITeam != "" {
+			cfg.Releases[i].CI = CI{
+				Provider: CI_PROVIDER_CONCOURSE,
+				Team:     release.CITeam,
+				Pipeline: release.CIPipeline,
+				Job:      release.CIBumpJob,
+			}
+		}
+		cfg.Releases[i].CI, err = resolveCI(cfg.Releases[i].CI, release.URL)
+		if err != nil {
+			return Config{}, err
+		}
+		if release.DevelopBranch == "" {
+			cfg.Releases[i].DevelopBranch = DEFAULT_DEVELOP_BRANCH
+		}
+		if release.TagPattern != "" {
+			cfg.Releases[i].TagRegexp, err = regexp.Compile(release.TagPattern)
+			if err != nil {
+				return Config{}, err
+			}
+		}
+		if release.BumpPRTitlePattern != "" {
+			cfg.Releases[i].BumpPRTitleRegexp, err = regexp.Compile(release.BumpPRTitlePattern)
+			if err != nil {
+				return Config{}, fmt.Errorf("failed to parse bump pull request title pattern of %s: %w", release.Name, err)
+			}
+		}
+		if len(release.Platforms) > 0 && (release.Platform != "" || release.TasReleaseName != "" || release.TaswReleaseName != "" || release.IstReleaseName != "") {
+			return Config{}, fmt.Errorf("release %s lists platforms, set the platform and tile names per platform", release.Name)
+		}
+		platforms := map[string]bool{}
+		for _, platform := range release.Platforms {
+			if platform.Platform == "" || platforms[platform.Platform] {
+				return Config{}, fmt.Errorf("release %s needs a unique name for every platform", release.Name)
+			}
+			platforms[platform.Platform] = true
+		}
+		for _, line := range release.ReleaseLines {
+			if line.Branch == "" {
+				return Config{}, fmt.Errorf("release line without branch for %s", release.Name)
+			}
+			_, err = regexp.Compile(line.TagPattern)
+			if err != nil {
+				return Config{}, err
+			}
+		}
+	}
+	for i, image := range cfg.Images {
+		cfg.Images[i].CI, err = resolveCI(image.CI, "")
 		if err != nil {
 			return Config{}, err
 		}
-		parts := strings.Split(strings.TrimLeft(url.Path, "/"), "/")
-		cfg.Releases[i].Owner = parts[0]
-		cfg.Releases[i].Repo = parts[1]
 	}
 	for i, plugin := range cfg.Plugins {
-		url, err := url.Parse(plugin.URL)
+		cfg.Plugins[i].Owner, cfg.Plugins[i].Repo, err = parseOwnerRepo(plugin.URL)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Plugins[i].CI, err = resolveCI(plugin.CI, plugin.URL)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	for i, buildpack := range cfg.Buildpacks {
+		cfg.Buildpacks[i].Owner, cfg.Buildpacks[i].Repo, err = parseOwnerRepo(buildpack.URL)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Buildpacks[i].CI, err = resolveCI(buildpack.CI, buildpack.URL)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	for i, externalProvider := range cfg.ExternalProviders {
+		if externalProvider.Name == "" || externalProvider.Command == "" {
+			return Config{}, fmt.Errorf("external provider needs a name and a command: %+v", externalProvider)
+		}
+		if externalProvider.Title == "" {
+			cfg.ExternalProviders[i].Title = externalProvider.Name
+		}
+		cfg.ExternalProviders[i].ParsedTimeout = DEFAULT_EXTERNAL_PROVIDER_TIMEOUT
+		if externalProvider.Timeout != "" {
+			cfg.ExternalProviders[i].ParsedTimeout, err = time.ParseDuration(externalProvider.Timeout)
+			if err != nil {
+				return Config{}, fmt.Errorf("failed to parse timeout of external provider %s: %w", externalProvider.Name, err)
+			}
+		}
+	}
+	for i, campaign := range cfg.Campaigns {
+		if campaign.Target == "" {
+			return Config{}, fmt.Errorf("campaign without target: %+v", campaign)
+		}
+		cfg.Campaigns[i].StartDate, err = time.Parse(CAMPAIGN_DATE_FORMAT, campaign.Start)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse start of campaign %s: %w", campaign.Target, err)
+		}
+		cfg.Campaigns[i].DeadlineDates = map[string]time.Time{}
+		for stage, deadline := range campaign.Deadlines {
+			deadlineDate, err := time.Parse(CAMPAIGN_DATE_FORMAT, deadline)
+			if err != nil {
+				return Config{}, fmt.Errorf("failed to parse %s deadline of campaign %s: %w", stage, campaign.Target, err)
+			}
+			if deadlineDate.Before(cfg.Campaigns[i].StartDate) {
+				return Config{}, fmt.Errorf("%s deadline of campaign %s is before its start", stage, campaign.Target)
+			}
+			cfg.Campaigns[i].DeadlineDates[stage] = deadlineDate
+		}
+	}
+	if cfg.TrackingIssue != nil {
+		cfg.TrackingIssue.Owner, cfg.TrackingIssue.Repo, err = parseOwnerRepo(cfg.TrackingIssue.URL)
 		if err != nil {
 			return Config{}, err
 		}
-		parts := strings.Split(strings.TrimLeft(url.Path, "/"), "/")
-		cfg.Plugins[i].Owner = parts[0]
-		cfg.Plugins[i].Repo = parts[1]
 	}
 	return cfg, nil
 }
+
+func resolveCI(ci CI, defaultURL string) (CI, error) {
+	var err error
+	switch ci.Provider {
+	case "", CI_PROVIDER_CONCOURSE:
+	case CI_PROVIDER_GITHUB_ACTIONS:
+		if ci.URL == "" {
+			ci.URL = defaultURL
+		}
+		ci.Owner, ci.Repo, err = parseOwnerRepo(ci.URL)
+		if err != nil {
+			return CI{}, err
+		}
+	default:
+		return CI{}, fmt.Errorf("unsupported ci provider: %s", ci.Provider)
+	}
+	return ci, nil
+}
+
+func parseOwnerRepo(rawURL string) (string, string, error) {
+	url, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.Split(strings.TrimLeft(url.Path, "/"), "/")
+	if len(parts) < 2 {
+		return "", "", fmt.Errorf("failed to parse owner and repo from url: %s", rawURL)
+	}
+	return parts[0], parts[1], nil
+}
@@ -3,6 +3,7 @@ package dataprovider
 import (
 	"context"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/cloudfoundry-incubator/golang-bump-progress/version"
@@ -22,6 +23,7 @@ type baseDataProvider struct {
 	githubClient  *github.Client
 	ctx           context.Context
 	lastFetchTime time.Time
+	fetchMux      sync.Mutex
 	cachedData    BaseData
 }
 
@@ -33,9 +35,13 @@ func NewBaseDataProvider(ctx context.Context, githubClient *github.Client) *base
 }
 
 func (p *baseDataProvider) Get() BaseData {
+	p.fetchMux.Lock()
+	defer p.fetchMux.Unlock()
 	if p.lastFetchTime.IsZero() || p.lastFetchTime.Add(FETCH_INTERVAL).Before(time.Now()) {
 		log.Println("Fetching new data for base template")
 		p.lastFetchTime = time.Now()
+		startRefresh(PROVIDER_BASE)
+		defer finishRefresh(PROVIDER_BASE)
 		p.cachedData = p.fetch()
 		return p.cachedData
 	}
@@ -47,19 +53,19 @@ func (p *baseDataProvider) fetch() BaseData {
 	data := BaseData{}
 	goVersionContent, _, _, err := p.githubClient.Repositories.GetContents(p.ctx, "cloudfoundry", "wg-app-platform-runtime-ci", "go-version.json", &github.RepositoryContentGetOptions{Ref: "main"})
 	if err != nil {
-		log.Printf("failed to get target go version: %s", err.Error())
+		logFailure(PROVIDER_BASE, "failed to get target go version: %s", err.Error())
 		return data
 	}
 	goVersionData, err := goVersionContent.GetContent()
 	if err != nil {
-		log.Printf("failed to get content of the target go version: %s", err.Error())
+		logFailure(PROVIDER_BASE, "failed to get content of the target go version: %s", err.Error())
 		return data
 	}
 
 	var goVersionResult GoVersionResult
 	err = yaml.Unmarshal([]byte(goVersionData), &goVersionResult)
 	if err != nil {
-		log.Printf("failed to parse content of the target go version: %s", err.Error())
+		logFailure(PROVIDER_BASE, "failed to parse content of the target go version: %s", err.Error())
 		return data
 	}
 	data.TargetGoVersion = version.MajorMinor(goVersionResult.Default)
@@ -0,0 +1,304 @@
+package dataprovider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/version"
+	"github.com/google/go-github/v54/github"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	CELL_MANIFEST = "ManifestGoVersion"
+	CELL_BUILD    = "BuildGoVersion"
+
+	STAGE_MANIFEST = "manifest"
+)
+
+type Buildpack struct {
+	Name              string
+	URL               string
+	Team              string
+	Ref               string
+	ManifestGoVersion string
+	BuildGoVersion    string
+	SkippedAssets     []string
+	CI                CIInfo
+	AllBumped         bool
+	Errors            map[string]*CellError
+}
+
+type BuildpacksData struct {
+	Buildpacks []Buildpack
+}
+
+type BuildpackManifestDependency struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+type BuildpackManifest struct {
+	Dependencies []BuildpackManifestDependency `yaml:"dependencies"`
+}
+
+type buildpacksDataProvider struct {
+	config        config.Config
+	ciStatus      ciStatusProvider
+	lastGood      *lastGoodValues
+	invalidated   *invalidations
+	lastFetchTime time.Time
+	fetchMux      sync.Mutex
+	cachedData    BuildpacksData
+	binaries      *releaseBinaryReader
+	githubClient  *github.Client
+	ctx           context.Context
+}
+
+func NewBuildpacksDataProvider(ctx context.Context, githubClient *github.Client, ciStatus ciStatusProvider, cache binaryInfoCache, cfg config.Config) *buildpacksDataProvider {
+	return &buildpacksDataProvider{
+		config:       cfg,
+		ciStatus:     ciStatus,
+		lastGood:     newLastGoodValues(),
+		invalidated:  newInvalidations(),
+		binaries:     newReleaseBinaryReader(ctx, githubClient, cache, PROVIDER_BUILDPACKS),
+		githubClient: githubClient,
+		ctx:          ctx,
+	}
+}
+
+func (p *buildpacksDataProvider) Get(targetGoVersion string) BuildpacksData {
+	p.fetchMux.Lock()
+	defer p.fetchMux.Unlock()
+	invalidated, _ := p.invalidated.take()
+	if p.lastFetchTime.IsZero() || p.lastFetchTime.Add(FETCH_INTERVAL).Before(time.Now()) {
+		log.Println("Fetching new data for template")
+		p.lastFetchTime = time.Now()
+		startRefresh(PROVIDER_BUILDPACKS)
+		defer finishRefresh(PROVIDER_BUILDPACKS)
+		p.cachedData = p.fetch(targetGoVersion)
+		return p.cachedData
+	}
+	if len(invalidated) > 0 {
+		startRefresh(PROVIDER_BUILDPACKS)
+		defer finishRefresh(PROVIDER_BUILDPACKS)
+		p.cachedData = p.refetch(invalidated, targetGoVersion)
+	}
+
+	return p.cachedData
+}
+
+// Invalidate marks the buildpacks of a repository to be refetched on the next
+// Get and reports whether the repository is configured.
+func (p *buildpacksDataProvider) Invalidate(owner string, repo string) bool {
+	found := false
+	for _, buildpack := range p.config.Buildpacks {
+		if sameRepo(buildpack.Owner, buildpack.Repo, owner, repo) {
+			p.invalidated.add(buildpack.Name)
+			found = true
+		}
+	}
+	return found
+}
+
+// fetch reads the latest released buildpack. A buildpack is bumped when the
+// Go its binaries are compiled with and, for buildpacks that ship Go as a dependency,
+// the highest Go in manifest.yml are at least the target version.
+func (p *buildpacksDataProvider) fetch(targetGoVersion string) BuildpacksData {
+	data := BuildpacksData{}
+	targetGolangV, err := semver.NewVersion(targetGoVersion)
+	if err != nil {
+		logFailure(PROVIDER_BUILDPACKS, "failed to parse target golang version: %s", targetGoVersion)
+	}
+	for _, buildpack := range p.config.Buildpacks {
+		data.Buildpacks = append(data.Buildpacks, p.fetchBuildpack(buildpack, targetGolangV))
+	}
+	return data
+}
+
+func (p *buildpacksDataProvider) fetchBuildpack(buildpack config.Buildpack, targetGolangV *semver.Version) Buildpack {
+	errs := map[string]*CellError{}
+	release, err := p.getRelease(buildpack)
+	if err != nil {
+		logFailure(PROVIDER_BUILDPACKS, "failed to get released version for %s: %s", buildpack.Name, err.Error())
+	}
+
+	var ref, buildGoVersion, manifestGoVersion string
+	var skippedAssets []string
+	if err == nil {
+		ref = release.GetTagName()
+		buildGoVersion, skippedAssets, err = p.getBuildGoVersion(buildpack, release)
+		if err != nil {
+			logFailure(PROVIDER_BUILDPACKS, "failed to get build golang version for %s: %s", buildpack.Name, err.Error())
+		}
+	}
+	buildGoVersion, errs[CELL_BUILD] = resolveCell(p.lastGood, buildpack.Name+"/build", buildGoVersion, err)
+
+	allBumped := isBumped(buildGoVersion, targetGolangV)
+	if buildpack.ManifestDependency != "" {
+		if ref != "" {
+			manifestGoVersion, err = p.getManifestGoVersion(buildpack, ref)
+			if err != nil {
+				logFailure(PROVIDER_BUILDPACKS, "failed to get manifest golang version for %s: %s", buildpack.Name, err.Error())
+			}
+		}
+		manifestGoVersion, errs[CELL_MANIFEST] = resolveCell(p.lastGood, buildpack.Name+"/manifest", manifestGoVersion, err)
+		allBumped = allBumped && isBumped(manifestGoVersion, targetGolangV)
+	}
+
+	return Buildpack{
+		Name:              buildpack.Name,
+		URL:               buildpack.URL,
+		Team:              ciTeam(buildpack.CI),
+		Ref:               ref,
+		ManifestGoVersion: manifestGoVersion,
+		BuildGoVersion:    buildGoVersion,
+		SkippedAssets:     skippedAssets,
+		CI:                getCIInfo(PROVIDER_BUILDPACKS, p.ciStatus, buildpack.Name, buildpack.CI),
+		AllBumped:         allBumped,
+		Errors:            errs,
+	}
+}
+
+// refetch fetches the invalidated buildpacks again and keeps the other rows.
+func (p *buildpacksDataProvider) refetch(invalidated map[string]bool, targetGoVersion string) BuildpacksData {
+	data := p.cachedData
+	data.Buildpacks = append([]Buildpack(nil), p.cachedData.Buildpacks...)
+	targetGolangV, _ := semver.NewVersion(targetGoVersion)
+	for i, buildpack := range p.config.Buildpacks {
+		if invalidated[buildpack.Name] && i < len(data.Buildpacks) {
+			data.Buildpacks[i] = p.fetchBuildpack(buildpack, targetGolangV)
+		}
+	}
+	return data
+}
+
+// getRelease picks the highest stable semver release, like for BOSH
+// releases, rather than the most recently created one.
+func (p *buildpacksDataProvider) getRelease(buildpack config.Buildpack) (*github.RepositoryRelease, error) {
+	publishedReleases, err := version.ListAllReleases(p.ctx, p.githubClient, buildpack.Owner, buildpack.Repo)
+	if err != nil {
+		return nil, err
+	}
+	if len(publishedReleases) < 1 {
+		return nil, version.NewNotFoundError(fmt.Errorf("no published releases for %s", buildpack.Name))
+	}
+	selected, err := version.SelectRelease(config.Release{Name: buildpack.Name, Owner: buildpack.Owner, Repo: buildpack.Repo}, publishedReleases)
+	if err != nil {
+		return nil, version.NewNotFoundError(err)
+	}
+	for _, publishedRelease := range publishedReleases {
+		if publishedRelease.GetTagName() == selected.Tag {
+			return publishedRelease, nil
+		}
+	}
+	return nil, version.NewNotFoundError(fmt.Errorf("release %s of %s not found", selected.Tag, buildpack.Name))
+}
+
+// getBuildGoVersion reads the Go that compiled the buildpack binaries from
+// the build info in the buildpack zips attached to the release. The oldest
+// one decides, as for plugins.
+func (p *buildpacksDataProvider) getBuildGoVersion(buildpack config.Buildpack, release *github.RepositoryRelease) (string, []string, error) {
+	binaries, skipped := p.binaries.getReleaseBinaries(buildpack.Owner, buildpack.Repo, release)
+	buildGoVersion := lowestGoVersion(binaries)
+	if buildGoVersion == "" {
+		return "", skipped, version.NewNotFoundError(fmt.Errorf("no Go binaries in the assets of %s %s", buildpack.Name, release.GetTagName()))
+	}
+	return buildGoVersion, skipped, nil
+}
+
+// getManifestGoVersion returns the highest version of the configured Go
+// dependency in manifest.yml.
+func (p *buildpacksDataProvider) getManifestGoVersion(buildpack config.Buildpack, ref string) (string, error) {
+	content, err := p.getFileContents(buildpack, "manifest.yml", ref)
+	if err != nil {
+		return "", err
+	}
+	var manifest BuildpackManifest
+	err = yaml.Unmarshal([]byte(content), &manifest)
+	if err != nil {
+		return "", err
+	}
+
+	var highest *semver.Version
+	for _, dependency := range manifest.Dependencies {
+		if dependency.Name != buildpack.ManifestDependency {
+			continue
+		}
+		dependencyV, err := semver.NewVersion(strings.TrimPrefix(dependency.Version, "go"))
+		if err != nil {
+			continue
+		}
+		if highest == nil || dependencyV.GreaterThan(highest) {
+			highest = dependencyV
+		}
+	}
+	if highest == nil {
+		return "", version.NewNotFoundError(fmt.Errorf("manifest.yml of %s has no %s dependency", buildpack.Name, buildpack.ManifestDependency))
+	}
+	return highest.String(), nil
+}
+
+func (p *buildpacksDataProvider) getFileContents(buildpack config.Buildpack, path string, ref string) (string, error) {
+	fileContent, _, response, err := p.githubClient.Repositories.GetContents(p.ctx, buildpack.Owner, buildpack.Repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return "", version.NewNotFoundError(err)
+		}
+		return "", err
+	}
+	return fileContent.GetContent()
+}
+
+func (p *buildpacksDataProvider) Kind() string {
+	return KIND_BUILDPACK
+}
+
+func (p *buildpacksDataProvider) Title() string {
+	return "Buildpacks"
+}
+
+// Rows reports the Go the buildpack is built with as its released stage and
+// the Go it ships, if any, as a manifest stage.
+func (p *buildpacksDataProvider) Rows(targetGoVersion string) []artifact.Row {
+	targetGolangV, _ := semver.NewVersion(targetGoVersion)
+	var rows []artifact.Row
+	for _, buildpack := range p.Get(targetGoVersion).Buildpacks {
+		stages := []artifact.Stage{{
+			Name:    artifact.STAGE_RELEASED,
+			Version: buildpack.BuildGoVersion,
+			URL:     tagURL(buildpack.URL, buildpack.Ref),
+			Bumped:  isBumped(buildpack.BuildGoVersion, targetGolangV),
+			Error:   buildpack.Errors[CELL_BUILD],
+		}}
+		if buildpack.ManifestGoVersion != "" || buildpack.Errors[CELL_MANIFEST] != nil {
+			stages = append(stages, artifact.Stage{
+				Name:    STAGE_MANIFEST,
+				Version: buildpack.ManifestGoVersion,
+				URL:     tagURL(buildpack.URL, buildpack.Ref),
+				Bumped:  isBumped(buildpack.ManifestGoVersion, targetGolangV),
+				Error:   buildpack.Errors[CELL_MANIFEST],
+			})
+		}
+		rows = append(rows, artifact.Row{
+			Kind:      KIND_BUILDPACK,
+			Name:      buildpack.Name,
+			URL:       buildpack.URL,
+			Team:      buildpack.Team,
+			Links:     ciLinks(buildpack.CI),
+			Stages:    stages,
+			Status:    artifact.Status(buildpack.AllBumped, stages),
+			AllBumped: buildpack.AllBumped,
+			Warnings:  skippedAssetWarnings(buildpack.SkippedAssets),
+		})
+	}
+	return rows
+}
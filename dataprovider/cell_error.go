@@ -0,0 +1,154 @@
+package dataprovider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/version"
+	"github.com/google/go-github/v54/github"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ERROR_NOT_FOUND     = "not-found"
+	ERROR_RATE_LIMITED  = "rate-limited"
+	ERROR_UNAUTHORIZED  = "unauthorized"
+	ERROR_PARSE_FAILURE = "parse-failure"
+	ERROR_TIMEOUT       = "timeout"
+	ERROR_UNKNOWN       = "error"
+
+	CELL_DEV            = "VersionOnDev"
+	CELL_RELEASED       = "ReleasedVersion"
+	CELL_FIRST_RELEASED = "FirstReleased"
+	CELL_TILES          = "Tiles"
+	CELL_VERSION        = "Version"
+)
+
+var (
+	errorExplanations = map[string]string{
+		ERROR_NOT_FOUND:     "not found",
+		ERROR_RATE_LIMITED:  "rate limited, will retry",
+		ERROR_UNAUTHORIZED:  "not authorized, check the token",
+		ERROR_PARSE_FAILURE: "could not parse the response",
+		ERROR_TIMEOUT:       "timed out, will retry",
+		ERROR_UNKNOWN:       "failed to fetch",
+	}
+)
+
+// CellError describes why a table cell could not be refreshed.
+type CellError = artifact.CellError
+
+// HTTPStatusError is returned for unexpected HTTP responses from APIs that
+// are called without a client library.
+type HTTPStatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s returned %d", e.URL, e.StatusCode)
+}
+
+func classifyError(err error) string {
+	var notFoundErr version.NotFoundError
+	var rateLimitErr *github.RateLimitError
+	var abuseRateLimitErr *github.AbuseRateLimitError
+	var githubErr *github.ErrorResponse
+	var httpStatusErr HTTPStatusError
+	var netErr net.Error
+	var jsonSyntaxErr *json.SyntaxError
+	var jsonTypeErr *json.UnmarshalTypeError
+	var yamlTypeErr *yaml.TypeError
+	var numErr *strconv.NumError
+
+	switch {
+	case errors.As(err, &notFoundErr):
+		return ERROR_NOT_FOUND
+	case errors.As(err, &rateLimitErr), errors.As(err, &abuseRateLimitErr):
+		return ERROR_RATE_LIMITED
+	case errors.As(err, &githubErr) && githubErr.Response != nil:
+		return classifyStatus(githubErr.Response.StatusCode)
+	case errors.As(err, &httpStatusErr):
+		return classifyStatus(httpStatusErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ERROR_TIMEOUT
+	case errors.Is(err, semver.ErrInvalidSemVer), errors.As(err, &jsonSyntaxErr), errors.As(err, &jsonTypeErr), errors.As(err, &yamlTypeErr), errors.As(err, &numErr):
+		return ERROR_PARSE_FAILURE
+	}
+	return ERROR_UNKNOWN
+}
+
+func classifyStatus(statusCode int) string {
+	switch statusCode {
+	case http.StatusNotFound:
+		return ERROR_NOT_FOUND
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ERROR_UNAUTHORIZED
+	case http.StatusTooManyRequests:
+		return ERROR_RATE_LIMITED
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return ERROR_TIMEOUT
+	}
+	return ERROR_UNKNOWN
+}
+
+type goodValue struct {
+	value interface{}
+	at    time.Time
+}
+
+// lastGoodValues remembers the last successfully fetched value of every cell
+// so that a failed refresh can keep showing it.
+type lastGoodValues struct {
+	values map[string]goodValue
+	mux    sync.Mutex
+}
+
+func newLastGoodValues() *lastGoodValues {
+	return &lastGoodValues{
+		values: map[string]goodValue{},
+	}
+}
+
+// resolveCell returns the fetched value when err is nil. Otherwise it returns
+// the last good value for the key, if any, and a CellError describing err.
+func resolveCell[T any](l *lastGoodValues, key string, value T, err error) (T, *CellError) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if err == nil {
+		l.values[key] = goodValue{value: value, at: time.Now()}
+		return value, nil
+	}
+
+	kind := classifyError(err)
+	cellErr := &CellError{
+		Kind:        kind,
+		Explanation: errorExplanations[kind],
+		Message:     err.Error(),
+	}
+	if good, ok := l.values[key]; ok {
+		cellErr.StaleFor = FormatAge(time.Since(good.at))
+		return good.value.(T), cellErr
+	}
+	return value, cellErr
+}
+
+func FormatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,63 @@
+package dataprovider
+
+import (
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/ci"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+)
+
+const (
+	CONCOURSE_ICON_URL = "/images/concourse-icon.png"
+)
+
+type CIInfo struct {
+	Provider      string
+	Status        string
+	URL           string
+	IconURL       string
+	BumpRunURL    string
+	BumpRunStatus string
+	BumpRunTime   string
+}
+
+type ciStatusProvider interface {
+	GetStatus(cfg config.CI) (ci.Status, error)
+}
+
+func getCIInfo(provider string, ciStatus ciStatusProvider, name string, cfg config.CI) CIInfo {
+	status, err := ciStatus.GetStatus(cfg)
+	if err != nil {
+		logFailure(provider, "failed to get CI status for %s: %s", name, err.Error())
+	}
+
+	info := CIInfo{
+		Provider:      status.Provider,
+		Status:        status.State,
+		URL:           status.URL,
+		BumpRunURL:    status.BumpRunURL,
+		BumpRunStatus: status.BumpRunStatus,
+	}
+	if status.Provider == config.CI_PROVIDER_CONCOURSE {
+		info.IconURL = CONCOURSE_ICON_URL
+	}
+	if !status.BumpRunTime.IsZero() {
+		info.BumpRunTime = status.BumpRunTime.UTC().Format("2006-01-02 15:04")
+	}
+	return info
+}
+
+// ciTeam is the team that owns an artifact: its Concourse team, or the GitHub
+// owner of its workflow.
+func ciTeam(cfg config.CI) string {
+	if cfg.Team != "" {
+		return cfg.Team
+	}
+	return cfg.Owner
+}
+
+func ciLinks(info CIInfo) []artifact.Link {
+	if info.URL == "" {
+		return nil
+	}
+	return []artifact.Link{{Title: "CI", URL: info.URL}}
+}
@@ -7,21 +7,32 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
 	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/metrics"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/version"
 )
 
 const (
 	DOCKERHUB_API_URL = "https://hub.docker.com/v2"
 )
 
+var (
+	dockerhubClient = &http.Client{Transport: metrics.NewTransport("dockerhub", http.DefaultTransport)}
+)
+
 type Image struct {
 	Name      string
 	URL       string
+	Team      string
 	Version   string
+	CI        CIInfo
 	AllBumped bool
+	Errors    map[string]*CellError
 }
 
 type ImagesData struct {
@@ -30,20 +41,29 @@ type ImagesData struct {
 
 type imagesDataProvider struct {
 	config        config.Config
+	ciStatus      ciStatusProvider
+	lastGood      *lastGoodValues
 	lastFetchTime time.Time
+	fetchMux      sync.Mutex
 	cachedData    ImagesData
 }
 
-func NewImagesDataProvider(cfg config.Config) *imagesDataProvider {
+func NewImagesDataProvider(ciStatus ciStatusProvider, cfg config.Config) *imagesDataProvider {
 	return &imagesDataProvider{
-		config: cfg,
+		config:   cfg,
+		ciStatus: ciStatus,
+		lastGood: newLastGoodValues(),
 	}
 }
 
 func (p *imagesDataProvider) Get(targetGoVersion string) ImagesData {
+	p.fetchMux.Lock()
+	defer p.fetchMux.Unlock()
 	if p.lastFetchTime.IsZero() || p.lastFetchTime.Add(FETCH_INTERVAL).Before(time.Now()) {
 		log.Println("Fetching new data for template")
 		p.lastFetchTime = time.Now()
+		startRefresh(PROVIDER_IMAGES)
+		defer finishRefresh(PROVIDER_IMAGES)
 		p.cachedData = p.fetch(targetGoVersion)
 		return p.cachedData
 	}
@@ -55,16 +75,21 @@ func (p *imagesDataProvider) fetch(targetGoVersion string) ImagesData {
 	data := ImagesData{}
 	targetGolangV, err := semver.NewVersion(targetGoVersion)
 	if err != nil {
-		log.Printf("failed to parse target golang version: %s", targetGoVersion)
+		logFailure(PROVIDER_IMAGES, "failed to parse target golang version: %s", targetGoVersion)
 	}
 	for _, image := range p.config.Images {
-		version := getDockerhubGoVersion(image.Name)
+		errs := map[string]*CellError{}
+		version, err := getDockerhubGoVersion(image.Name)
+		if err != nil {
+			logFailure(PROVIDER_IMAGES, "failed to get golang version for image %s: %s", image.Name, err.Error())
+		}
+		version, errs[CELL_VERSION] = resolveCell(p.lastGood, image.Name, version, err)
 
 		allBumped := false
-		if targetGolangV != nil {
+		if targetGolangV != nil && version != "" {
 			imageV, err := semver.NewVersion(version)
 			if err != nil {
-				log.Printf("failed to parse image version for %s: %s", image.Name, err.Error())
+				logFailure(PROVIDER_IMAGES, "failed to parse image version for %s: %s", image.Name, err.Error())
 			} else {
 				if !targetGolangV.GreaterThan(imageV) {
 					allBumped = true
@@ -75,8 +100,11 @@ func (p *imagesDataProvider) fetch(targetGoVersion string) ImagesData {
 		data.Images = append(data.Images, Image{
 			Name:      image.Name,
 			URL:       image.URL,
+			Team:      ciTeam(image.CI),
 			Version:   version,
+			CI:        getCIInfo(PROVIDER_IMAGES, p.ciStatus, image.Name, image.CI),
 			AllBumped: allBumped,
+			Errors:    errs,
 		})
 	}
 	return data
@@ -90,35 +118,69 @@ type DockerhubTagsResponse struct {
 	Results []DockerhubTagsResult
 }
 
-func getDockerhubGoVersion(imageName string) string {
+func getDockerhubGoVersion(imageName string) (string, error) {
 	url := fmt.Sprintf("%s/repositories/%s/tags?ordering=last_updated&page_size=3", DOCKERHUB_API_URL, imageName)
-	res, err := http.Get(url)
+	res, err := dockerhubClient.Get(url)
 	if err != nil {
-		log.Printf("failed to get tags for image %s: %s", imageName, err.Error())
-		return ""
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", HTTPStatusError{StatusCode: res.StatusCode, URL: url}
 	}
 
 	bytes, err := io.ReadAll(res.Body)
 	if err != nil {
-		log.Printf("failed to read tags body for image %s: %s", imageName, err.Error())
-		return ""
+		return "", err
 	}
 
 	var response DockerhubTagsResponse
 	err = json.Unmarshal(bytes, &response)
 	if err != nil {
-		log.Printf("failed to parse tags body for image %s: %s", imageName, err.Error())
-		return ""
+		return "", err
 	}
 
 	for _, result := range response.Results {
 		if strings.HasPrefix(result.Name, "go-") {
 			parsedGoVersion := strings.Split(result.Name, "go-")
 			if len(parsedGoVersion) == 2 {
-				return parsedGoVersion[1]
+				return parsedGoVersion[1], nil
 			}
 		}
 	}
 
-	return ""
+	return "", version.NewNotFoundError(fmt.Errorf("no go- tag among the latest tags of %s", imageName))
+}
+
+func (p *imagesDataProvider) Kind() string {
+	return KIND_IMAGE
+}
+
+func (p *imagesDataProvider) Title() string {
+	return "Images"
+}
+
+func (p *imagesDataProvider) Rows(targetGoVersion string) []artifact.Row {
+	var rows []artifact.Row
+	for _, image := range p.Get(targetGoVersion).Images {
+		stages := []artifact.Stage{{
+			Name:    artifact.STAGE_RELEASED,
+			Version: image.Version,
+			URL:     image.URL,
+			Bumped:  image.AllBumped,
+			Error:   image.Errors[CELL_VERSION],
+		}}
+		rows = append(rows, artifact.Row{
+			Kind:      KIND_IMAGE,
+			Name:      image.Name,
+			URL:       image.URL,
+			Team:      image.Team,
+			Links:     ciLinks(image.CI),
+			Stages:    stages,
+			Status:    artifact.Status(image.AllBumped, stages),
+			AllBumped: image.AllBumped,
+		})
+	}
+	return rows
 }
@@ -0,0 +1,47 @@
+package dataprovider
+
+import (
+	"strings"
+	"sync"
+)
+
+// invalidations records rows that must be refetched before FETCH_INTERVAL
+// expires, for example after a webhook. It has its own lock so that rows can
+// be invalidated while a provider is fetching.
+type invalidations struct {
+	names map[string]bool
+	all   bool
+	mux   sync.Mutex
+}
+
+func newInvalidations() *invalidations {
+	return &invalidations{
+		names: map[string]bool{},
+	}
+}
+
+func (i *invalidations) add(name string) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.names[name] = true
+}
+
+func (i *invalidations) addAll() {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	i.all = true
+}
+
+// take returns and clears the invalidated row names and whether every row
+// was invalidated.
+func (i *invalidations) take() (map[string]bool, bool) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	names, all := i.names, i.all
+	i.names, i.all = map[string]bool{}, false
+	return names, all
+}
+
+func sameRepo(owner string, repo string, otherOwner string, otherRepo string) bool {
+	return strings.EqualFold(owner, otherOwner) && strings.EqualFold(repo, otherRepo)
+}
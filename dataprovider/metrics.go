@@ -0,0 +1,73 @@
+package dataprovider
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/metrics"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/version"
+)
+
+type goMinorCalendar interface {
+	Minor(golangVersion string) (version.GoMinor, bool)
+}
+
+// metricsUpdater sets the per-row gauges from rows that were already read,
+// so that a scrape only reads the gauges and never refreshes a provider.
+// Lag is counted from the release of the target golang minor and is not
+// reported while the calendar has no entry for it.
+type metricsUpdater struct {
+	calendar goMinorCalendar
+	mux      sync.Mutex
+}
+
+func NewMetricsUpdater(calendar goMinorCalendar) *metricsUpdater {
+	return &metricsUpdater{
+		calendar: calendar,
+	}
+}
+
+// Update replaces the per-row gauges with the given rows of the target.
+func (u *metricsUpdater) Update(targetGoVersion string, rows []artifact.Row, now time.Time) {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	minor, hasMinor := u.calendar.Minor(targetGoVersion)
+
+	for _, vec := range []*metrics.Vec{metrics.BumpedOnDev, metrics.Released, metrics.Shipped, metrics.AllBumped, metrics.LagDays} {
+		vec.Reset()
+	}
+	for _, row := range rows {
+		// a stage of a release with several platforms is bumped once it is
+		// bumped on every platform
+		bumped := map[string]bool{}
+		for _, stage := range row.Stages {
+			wasBumped, seen := bumped[stage.Name]
+			bumped[stage.Name] = stage.Bumped && (wasBumped || !seen)
+		}
+		for _, stage := range row.Stages {
+			switch {
+			case stage.Tile:
+				metrics.Shipped.Set(boolValue(bumped[stage.Name]), row.Kind, row.Name, stage.Name)
+			case stage.Name == artifact.STAGE_DEVELOP:
+				metrics.BumpedOnDev.Set(boolValue(bumped[stage.Name]), row.Kind, row.Name)
+			case stage.Name == artifact.STAGE_RELEASED:
+				metrics.Released.Set(boolValue(bumped[stage.Name]), row.Kind, row.Name)
+			}
+		}
+		metrics.AllBumped.Set(boolValue(row.AllBumped), row.Kind, row.Name)
+		switch {
+		case row.AllBumped:
+			metrics.LagDays.Set(0, row.Kind, row.Name)
+		case hasMinor:
+			metrics.LagDays.Set(now.Sub(minor.Released).Hours()/24, row.Kind, row.Name)
+		}
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -1,9 +1,83 @@
 package dataprovider // import "github.com/cloudfoundry-incubator/golang-bump-progress/dataprovider"
 
 import (
+	"log"
+	"sync"
 	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/metrics"
 )
 
 const (
 	FETCH_INTERVAL = time.Minute
+
+	PROVIDER_BASE       = "base"
+	PROVIDER_RELEASES   = "releases"
+	PROVIDER_IMAGES     = "images"
+	PROVIDER_PLUGINS    = "plugins"
+	PROVIDER_BUILDPACKS = "buildpacks"
+	PROVIDER_MODULES    = "modules"
+
+	KIND_RELEASE   = "release"
+	KIND_IMAGE     = "image"
+	KIND_PLUGIN    = "plugin"
+	KIND_BUILDPACK = "buildpack"
+	KIND_MODULE    = "module"
+)
+
+type RefreshState struct {
+	Started   time.Time     `json:"started"`
+	Duration  time.Duration `json:"duration"`
+	Failures  int           `json:"failures"`
+	Refreshes int           `json:"refreshes"`
+}
+
+var (
+	refreshStates    = map[string]RefreshState{}
+	pendingRefreshes = map[string]RefreshState{}
+	refreshStatesMux sync.Mutex
 )
+
+// RefreshStates returns the outcome of the last completed refresh of every
+// data provider.
+func RefreshStates() map[string]RefreshState {
+	refreshStatesMux.Lock()
+	defer refreshStatesMux.Unlock()
+	states := map[string]RefreshState{}
+	for provider, state := range refreshStates {
+		states[provider] = state
+	}
+	return states
+}
+
+func startRefresh(provider string) {
+	refreshStatesMux.Lock()
+	defer refreshStatesMux.Unlock()
+	pendingRefreshes[provider] = RefreshState{
+		Started:   time.Now(),
+		Refreshes: refreshStates[provider].Refreshes + 1,
+	}
+}
+
+func finishRefresh(provider string) {
+	refreshStatesMux.Lock()
+	defer refreshStatesMux.Unlock()
+	state := pendingRefreshes[provider]
+	state.Duration = time.Since(state.Started)
+	refreshStates[provider] = state
+	delete(pendingRefreshes, provider)
+
+	metrics.RefreshTotal.Inc(provider)
+	metrics.RefreshDuration.Set(state.Duration.Seconds(), provider)
+}
+
+func logFailure(provider string, format string, args ...interface{}) {
+	metrics.RefreshFailures.Inc(provider)
+	refreshStatesMux.Lock()
+	if state, ok := pendingRefreshes[provider]; ok {
+		state.Failures++
+		pendingRefreshes[provider] = state
+	}
+	refreshStatesMux.Unlock()
+	log.Printf(format, args...)
+}
@@ -2,20 +2,36 @@ package dataprovider
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
 	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/version"
 	"github.com/google/go-github/v54/github"
 )
 
+var (
+	BUILT_WITH_GO_RE = regexp.MustCompile(`Built with go ([\d\.]*)`)
+)
+
 type Plugin struct {
 	Name            string
 	URL             string
+	Team            string
+	ReleasedTag     string
 	ReleasedVersion string
+	NotesVersion    string
+	NotesMismatch   bool
+	Binaries        []ReleaseBinary
+	SkippedAssets   []string
+	CI              CIInfo
 	AllBumped       bool
+	Errors          map[string]*CellError
 }
 
 type PluginsData struct {
@@ -24,76 +40,201 @@ type PluginsData struct {
 
 type pluginsDataProvider struct {
 	config        config.Config
+	ciStatus      ciStatusProvider
+	lastGood      *lastGoodValues
+	invalidated   *invalidations
 	lastFetchTime time.Time
+	fetchMux      sync.Mutex
 	cachedData    PluginsData
+	binaries      *releaseBinaryReader
 	githubClient  *github.Client
 	ctx           context.Context
 }
 
-func NewPluginsDataProvider(ctx context.Context, githubClient *github.Client, cfg config.Config) *pluginsDataProvider {
+func NewPluginsDataProvider(ctx context.Context, githubClient *github.Client, ciStatus ciStatusProvider, cache binaryInfoCache, cfg config.Config) *pluginsDataProvider {
 	return &pluginsDataProvider{
 		config:       cfg,
+		ciStatus:     ciStatus,
+		lastGood:     newLastGoodValues(),
+		invalidated:  newInvalidations(),
+		binaries:     newReleaseBinaryReader(ctx, githubClient, cache, PROVIDER_PLUGINS),
 		githubClient: githubClient,
 		ctx:          ctx,
 	}
 }
 
 func (p *pluginsDataProvider) Get(targetGoVersion string) PluginsData {
+	p.fetchMux.Lock()
+	defer p.fetchMux.Unlock()
+	invalidated, _ := p.invalidated.take()
 	if p.lastFetchTime.IsZero() || p.lastFetchTime.Add(FETCH_INTERVAL).Before(time.Now()) {
 		log.Println("Fetching new data for template")
 		p.lastFetchTime = time.Now()
+		startRefresh(PROVIDER_PLUGINS)
+		defer finishRefresh(PROVIDER_PLUGINS)
 		p.cachedData = p.fetch(targetGoVersion)
 		return p.cachedData
 	}
+	if len(invalidated) > 0 {
+		startRefresh(PROVIDER_PLUGINS)
+		defer finishRefresh(PROVIDER_PLUGINS)
+		p.cachedData = p.refetch(invalidated, targetGoVersion)
+	}
 
 	return p.cachedData
 }
 
+// Invalidate marks the plugins of a repository to be refetched on the next
+// Get and reports whether the repository is configured.
+func (p *pluginsDataProvider) Invalidate(owner string, repo string) bool {
+	found := false
+	for _, plugin := range p.config.Plugins {
+		if sameRepo(plugin.Owner, plugin.Repo, owner, repo) {
+			p.invalidated.add(plugin.Name)
+			found = true
+		}
+	}
+	return found
+}
+
 func (p *pluginsDataProvider) fetch(targetGoVersion string) PluginsData {
 	data := PluginsData{}
 	targetGolangV, err := semver.NewVersion(targetGoVersion)
 	if err != nil {
-		log.Printf("failed to parse target golang version: %s", targetGoVersion)
+		logFailure(PROVIDER_PLUGINS, "failed to parse target golang version: %s", targetGoVersion)
 	}
 	for _, plugin := range p.config.Plugins {
-		releasedVersion := p.getReleasedVersion(plugin)
-
-		allBumped := false
-		if targetGolangV != nil {
-			pluginV, err := semver.NewVersion(releasedVersion)
-			if err != nil {
-				log.Printf("failed to parse plugin version %s for %s: %s", releasedVersion, plugin.Name, err.Error())
-			} else {
-				if !targetGolangV.GreaterThan(pluginV) {
-					allBumped = true
-				}
+		data.Plugins = append(data.Plugins, p.fetchPlugin(plugin, targetGolangV))
+	}
+	return data
+}
+
+func (p *pluginsDataProvider) fetchPlugin(plugin config.Plugin, targetGolangV *semver.Version) Plugin {
+	errs := map[string]*CellError{}
+	released, err := p.getReleasedVersion(plugin)
+	if err != nil {
+		logFailure(PROVIDER_PLUGINS, "failed to get released version for %s: %s", plugin.Name, err.Error())
+	}
+	released, errs[CELL_RELEASED] = resolveCell(p.lastGood, plugin.Name, released, err)
+	releasedVersion := released.GolangVersion
+
+	allBumped := false
+	if targetGolangV != nil && releasedVersion != "" {
+		pluginV, err := semver.NewVersion(releasedVersion)
+		if err != nil {
+			logFailure(PROVIDER_PLUGINS, "failed to parse plugin version %s for %s: %s", releasedVersion, plugin.Name, err.Error())
+		} else {
+			if !targetGolangV.GreaterThan(pluginV) {
+				allBumped = true
 			}
 		}
+	}
 
-		data.Plugins = append(data.Plugins, Plugin{
-			Name:            plugin.Name,
-			URL:             plugin.URL,
-			ReleasedVersion: releasedVersion,
-			AllBumped:       allBumped,
-		})
+	return Plugin{
+		Name:            plugin.Name,
+		URL:             plugin.URL,
+		Team:            ciTeam(plugin.CI),
+		ReleasedTag:     released.Tag,
+		ReleasedVersion: releasedVersion,
+		NotesVersion:    released.NotesVersion,
+		NotesMismatch:   notesMismatch(released.NotesVersion, released.Binaries),
+		Binaries:        released.Binaries,
+		SkippedAssets:   released.SkippedAssets,
+		CI:              getCIInfo(PROVIDER_PLUGINS, p.ciStatus, plugin.Name, plugin.CI),
+		AllBumped:       allBumped,
+		Errors:          errs,
+	}
+}
+
+// refetch fetches the invalidated plugins again and keeps the other rows.
+func (p *pluginsDataProvider) refetch(invalidated map[string]bool, targetGoVersion string) PluginsData {
+	data := p.cachedData
+	data.Plugins = append([]Plugin(nil), p.cachedData.Plugins...)
+	targetGolangV, _ := semver.NewVersion(targetGoVersion)
+	for i, plugin := range p.config.Plugins {
+		if invalidated[plugin.Name] && i < len(data.Plugins) {
+			data.Plugins[i] = p.fetchPlugin(plugin, targetGolangV)
+		}
 	}
 	return data
 }
 
-func (p *pluginsDataProvider) getReleasedVersion(plugin config.Plugin) string {
+type pluginRelease struct {
+	Tag           string
+	GolangVersion string
+	NotesVersion  string
+	Binaries      []ReleaseBinary
+	SkippedAssets []string
+}
+
+// getReleasedVersion prefers the Go version embedded in the release binaries
+// and falls back to the "Built with go" line of the release notes.
+func (p *pluginsDataProvider) getReleasedVersion(plugin config.Plugin) (pluginRelease, error) {
 	publishedReleases, _, err := p.githubClient.Repositories.ListReleases(p.ctx, plugin.Owner, plugin.Repo, &github.ListOptions{PerPage: 1})
 	if err != nil {
-		return ""
+		return pluginRelease{}, err
 	}
 	if len(publishedReleases) < 1 {
-		return ""
+		return pluginRelease{}, version.NewNotFoundError(fmt.Errorf("no published releases for %s", plugin.Name))
+	}
+	released := pluginRelease{Tag: publishedReleases[0].GetTagName()}
+	matches := BUILT_WITH_GO_RE.FindStringSubmatch(publishedReleases[0].GetBody())
+	if len(matches) == 2 {
+		released.NotesVersion = matches[1]
 	}
-	releaseBody := publishedReleases[0].GetBody()
-	re := regexp.MustCompile(`Built with go ([\d\.]*)`)
-	matches := re.FindStringSubmatch(releaseBody)
-	if len(matches) < 2 {
-		return ""
+
+	released.Binaries, released.SkippedAssets = p.binaries.getReleaseBinaries(plugin.Owner, plugin.Repo, publishedReleases[0])
+	released.GolangVersion = lowestGoVersion(released.Binaries)
+	if released.GolangVersion == "" {
+		released.GolangVersion = released.NotesVersion
+	}
+	if released.GolangVersion == "" {
+		return pluginRelease{}, version.NewNotFoundError(fmt.Errorf("neither the binaries nor the release notes of %s mention the golang version", released.Tag))
+	}
+	return released, nil
+}
+
+func (p *pluginsDataProvider) Kind() string {
+	return KIND_PLUGIN
+}
+
+func (p *pluginsDataProvider) Title() string {
+	return "Plugins"
+}
+
+func (p *pluginsDataProvider) Rows(targetGoVersion string) []artifact.Row {
+	var rows []artifact.Row
+	for _, plugin := range p.Get(targetGoVersion).Plugins {
+		stages := []artifact.Stage{{
+			Name:    artifact.STAGE_RELEASED,
+			Version: plugin.ReleasedVersion,
+			URL:     tagURL(plugin.URL, plugin.ReleasedTag),
+			Bumped:  plugin.AllBumped,
+			Error:   plugin.Errors[CELL_RELEASED],
+		}}
+		links := ciLinks(plugin.CI)
+		for _, binary := range plugin.Binaries {
+			links = append(links, artifact.Link{Title: binary.OS + "/" + binary.Arch, URL: binary.URL})
+		}
+		rows = append(rows, artifact.Row{
+			Kind:      KIND_PLUGIN,
+			Name:      plugin.Name,
+			URL:       plugin.URL,
+			Team:      plugin.Team,
+			Links:     links,
+			Stages:    stages,
+			Status:    artifact.Status(plugin.AllBumped, stages),
+			AllBumped: plugin.AllBumped,
+			Warnings:  skippedAssetWarnings(plugin.SkippedAssets),
+		})
 	}
+	return rows
+}
 
-	return matches[1]
+func skippedAssetWarnings(skippedAssets []string) []string {
+	var warnings []string
+	for _, skipped := range skippedAssets {
+		warnings = append(warnings, "skipped asset "+skipped)
+	}
+	return warnings
 }
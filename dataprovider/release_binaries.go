@@ -0,0 +1,326 @@
+package dataprovider
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/metrics"
+	"github.com/google/go-github/v54/github"
+)
+
+const (
+	MAX_RELEASE_BINARY_SIZE = 200 * 1024 * 1024
+
+	SKIPPED_TOO_LARGE   = "too large"
+	SKIPPED_UNSUPPORTED = "unsupported archive"
+	SKIPPED_FAILED      = "failed to read"
+)
+
+var (
+	assetDownloadClient = &http.Client{Transport: metrics.NewTransport("github-assets", http.DefaultTransport)}
+
+	unsupportedArchiveSuffixes = []string{".tar.xz", ".txz", ".tar.bz2", ".tbz2", ".tar.zst", ".7z", ".rar"}
+)
+
+// ReleaseBinary is the Go build info embedded in one release asset, or in the
+// first Go binary of a .tgz, .tar.gz or .zip asset. Assets that are not Go
+// binaries, such as checksum files, have an empty GoVersion. Skipped says why
+// an asset could not be read.
+type ReleaseBinary struct {
+	Asset     string
+	URL       string
+	OS        string
+	Arch      string
+	GoVersion string
+	Skipped   string
+}
+
+type binaryInfoCache interface {
+	Get(key string, value interface{}) bool
+	Set(key string, value interface{}) error
+}
+
+// releaseBinaryReader reads the Go build info of release assets for the
+// plugins and buildpacks providers.
+type releaseBinaryReader struct {
+	provider     string
+	githubClient *github.Client
+	cache        binaryInfoCache
+	ctx          context.Context
+}
+
+func newReleaseBinaryReader(ctx context.Context, githubClient *github.Client, cache binaryInfoCache, provider string) *releaseBinaryReader {
+	return &releaseBinaryReader{
+		provider:     provider,
+		githubClient: githubClient,
+		cache:        cache,
+		ctx:          ctx,
+	}
+}
+
+// getReleaseBinaries reads the build info of every asset of the release and
+// returns the Go binaries along with notes on the assets that were skipped.
+// Assets are immutable, so results are cached by asset ID. Assets that fail
+// to download or extract are skipped and not cached, so they are read again
+// on the next refresh.
+func (r *releaseBinaryReader) getReleaseBinaries(owner string, repo string, release *github.RepositoryRelease) ([]ReleaseBinary, []string) {
+	var binaries []ReleaseBinary
+	var skipped []string
+	for _, asset := range release.Assets {
+		if asset.GetSize() > MAX_RELEASE_BINARY_SIZE {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", asset.GetName(), SKIPPED_TOO_LARGE))
+			continue
+		}
+		cacheKey := fmt.Sprintf("release-binary/%s/%s/%d", owner, repo, asset.GetID())
+		var binary ReleaseBinary
+		if !r.cache.Get(cacheKey, &binary) {
+			var err error
+			binary, err = r.inspectAsset(owner, repo, asset)
+			if err != nil {
+				logFailure(r.provider, "failed to read build info of %s of %s/%s: %s", asset.GetName(), owner, repo, err.Error())
+				skipped = append(skipped, fmt.Sprintf("%s: %s", asset.GetName(), SKIPPED_FAILED))
+				continue
+			}
+			err = r.cache.Set(cacheKey, binary)
+			if err != nil {
+				logFailure(r.provider, "failed to cache build info of %s: %s", asset.GetName(), err.Error())
+			}
+		}
+		if binary.Skipped != "" {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", asset.GetName(), binary.Skipped))
+		}
+		if binary.GoVersion != "" {
+			binaries = append(binaries, binary)
+		}
+	}
+	sort.Slice(binaries, func(i, j int) bool {
+		return binaries[i].OS+binaries[i].Arch < binaries[j].OS+binaries[j].Arch
+	})
+	return binaries, skipped
+}
+
+func (r *releaseBinaryReader) inspectAsset(owner string, repo string, asset *github.ReleaseAsset) (ReleaseBinary, error) {
+	binary := ReleaseBinary{
+		Asset: asset.GetName(),
+		URL:   asset.GetBrowserDownloadURL(),
+	}
+	name := strings.ToLower(asset.GetName())
+	for _, suffix := range unsupportedArchiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			binary.Skipped = SKIPPED_UNSUPPORTED
+			return binary, nil
+		}
+	}
+
+	rc, _, err := r.githubClient.Repositories.DownloadReleaseAsset(r.ctx, owner, repo, asset.GetID(), assetDownloadClient)
+	if err != nil {
+		return binary, err
+	}
+	defer rc.Close()
+
+	tmpFile, err := os.CreateTemp("", "release-binary")
+	if err != nil {
+		return binary, err
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = io.Copy(tmpFile, io.LimitReader(rc, MAX_RELEASE_BINARY_SIZE))
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return binary, err
+	}
+
+	var info *buildinfo.BuildInfo
+	switch {
+	case strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".tar.gz"):
+		info, binary.Skipped, err = readTgzBuildInfo(tmpFile.Name())
+	case strings.HasSuffix(name, ".zip"):
+		info, binary.Skipped, err = readZipBuildInfo(tmpFile.Name())
+	default:
+		// not a Go binary when there is no build info
+		info, _ = buildinfo.ReadFile(tmpFile.Name())
+	}
+	if err != nil {
+		return binary, err
+	}
+	if info == nil {
+		return binary, nil
+	}
+	// drop experiment suffixes such as "go1.22.1 X:loopvar"
+	goVersion, _, _ := strings.Cut(info.GoVersion, " ")
+	binary.GoVersion = strings.TrimPrefix(goVersion, "go")
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "GOOS":
+			binary.OS = setting.Value
+		case "GOARCH":
+			binary.Arch = setting.Value
+		}
+	}
+	return binary, nil
+}
+
+// readTgzBuildInfo returns the build info of the first Go binary in a
+// gzipped tarball. A corrupt archive is reported as unsupported rather than
+// failing the release.
+func readTgzBuildInfo(archivePath string) (*buildinfo.BuildInfo, string, error) {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer archive.Close()
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, SKIPPED_UNSUPPORTED, nil
+	}
+	defer gz.Close()
+
+	tooLarge := false
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, SKIPPED_UNSUPPORTED, nil
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > MAX_RELEASE_BINARY_SIZE {
+			tooLarge = true
+			continue
+		}
+		info, err := readMemberBuildInfo(tr, header.Size)
+		if err != nil {
+			return nil, "", err
+		}
+		if info != nil {
+			return info, "", nil
+		}
+	}
+	if tooLarge {
+		return nil, SKIPPED_TOO_LARGE, nil
+	}
+	return nil, "", nil
+}
+
+// readZipBuildInfo returns the build info of the first Go binary in a zip
+// archive.
+func readZipBuildInfo(archivePath string) (*buildinfo.BuildInfo, string, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, SKIPPED_UNSUPPORTED, nil
+	}
+	defer zr.Close()
+
+	tooLarge := false
+	for _, file := range zr.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		if file.UncompressedSize64 > MAX_RELEASE_BINARY_SIZE {
+			tooLarge = true
+			continue
+		}
+		member, err := file.Open()
+		if err != nil {
+			return nil, SKIPPED_UNSUPPORTED, nil
+		}
+		info, err := readMemberBuildInfo(member, int64(file.UncompressedSize64))
+		member.Close()
+		if err != nil {
+			return nil, "", err
+		}
+		if info != nil {
+			return info, "", nil
+		}
+	}
+	if tooLarge {
+		return nil, SKIPPED_TOO_LARGE, nil
+	}
+	return nil, "", nil
+}
+
+// readMemberBuildInfo copies an archive member to a temporary file, since
+// buildinfo needs random access, and reads its build info if it has any.
+func readMemberBuildInfo(member io.Reader, size int64) (*buildinfo.BuildInfo, error) {
+	tmpFile, err := os.CreateTemp("", "release-binary-member")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = io.Copy(tmpFile, io.LimitReader(member, size))
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := buildinfo.ReadFile(tmpFile.Name())
+	if err != nil {
+		// not a Go binary
+		return nil, nil
+	}
+	return info, nil
+}
+
+// lowestGoVersion returns the oldest Go version among the binaries, which is
+// the one that decides whether the plugin is bumped.
+func lowestGoVersion(binaries []ReleaseBinary) string {
+	var lowest *semver.Version
+	var lowestVersion string
+	for _, binary := range binaries {
+		binaryV, err := semver.NewVersion(binary.GoVersion)
+		if err != nil {
+			continue
+		}
+		if lowest == nil || binaryV.LessThan(lowest) {
+			lowest = binaryV
+			lowestVersion = binary.GoVersion
+		}
+	}
+	return lowestVersion
+}
+
+// notesMismatch reports whether any binary was built with a different Go
+// version than the release notes claim. Notes without a patch version, such
+// as "Built with go 1.21", only claim the minor.
+func notesMismatch(notesVersion string, binaries []ReleaseBinary) bool {
+	notesVersion = strings.TrimSuffix(notesVersion, ".")
+	if notesVersion == "" {
+		return false
+	}
+	notesV, err := semver.NewVersion(notesVersion)
+	if err != nil {
+		logFailure(PROVIDER_PLUGINS, "failed to parse golang version %s in release notes: %s", notesVersion, err.Error())
+		return false
+	}
+	minorOnly := strings.Count(notesVersion, ".") < 2
+	for _, binary := range binaries {
+		binaryV, err := semver.NewVersion(binary.GoVersion)
+		if err != nil {
+			continue
+		}
+		if minorOnly {
+			if binaryV.Major() != notesV.Major() || binaryV.Minor() != notesV.Minor() {
+				return true
+			}
+		} else if !binaryV.Equal(notesV) {
+			return true
+		}
+	}
+	return false
+}
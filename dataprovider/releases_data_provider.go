@@ -3,9 +3,13 @@ package dataprovider
 import (
 	"fmt"
 	"log"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
 	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
 	"github.com/cloudfoundry-incubator/golang-bump-progress/version"
 )
@@ -13,135 +17,484 @@ import (
 type Release struct {
 	Name                        string
 	URL                         string
+	Team                        string
+	Platform                    string
+	PlatformRows                int
+	DevelopBranch               string
 	VersionOnDev                string
 	ReleasedVersion             string
+	ReleasedTag                 string
+	ReleasedTagReason           string
 	FirstReleasedGolangVersion  string
 	FirstReleasedReleaseVersion string
+	FirstReleasedTag            string
+	FirstReleasedAt             string
+	FirstPatchGolangVersion     string
+	FirstPatchReleaseVersion    string
+	FirstPatchReleasedAt        string
 	BumpedInTas                 string
 	BumpedInTasw                string
 	BumpedInIst                 string
-	CIURL                       string
-	CIBadgeURL                  string
+	CI                          CIInfo
+	BumpPullRequests            []version.PullRequestInfo
 	AllBumped                   bool
+	Errors                      map[string]*CellError
+	GoModWarnings               map[string][]string
+	NotVendored                 map[string]bool
 }
 
 type ReleasesData struct {
+	Subject       string
 	GolangVersion string
 	Releases      []Release
 }
 
 type versionFetcher interface {
-	GetDevelopVersion(release config.Release) (string, error)
-	GetReleasedVersion(release config.Release) (string, error)
-	GetFirstReleasedVersion(release config.Release, releasedVersion string) (version.VersionInfo, error)
+	GetDevelopVersion(release config.Release) (version.DevelopVersionInfo, error)
+	GetReleasedVersion(release config.Release) (version.ReleasedVersionInfo, error)
+	GetFirstReleasedVersion(release config.Release, releasedVersion version.ReleasedVersionInfo) (version.VersionInfo, error)
+}
+
+// refPrefetcher is implemented by version fetchers that can read the
+// development branches or released tags of many releases at once.
+type refPrefetcher interface {
+	PrefetchDevelop(releases []config.Release) error
+	PrefetchReleased(releases []config.Release) error
 }
 
 type tasVersionProvider interface {
 	Fetch(ref string) error
+	Invalidate()
 	GetTasReleaseVersion(releaseName string) (string, bool)
 	GetTaswReleaseVersion(releaseName string) (string, bool)
 	GetIstReleaseVersion(releaseName string) (string, bool)
+	GetKilnfileChangeURL(fileName string) string
+}
+
+// goModChecker is implemented by version fetchers that can check the go.mod
+// files of a release against its packaged golang version.
+type goModChecker interface {
+	GetDevelopGoModMismatches(release config.Release, golangVersion string) ([]version.GoModMismatch, error)
+	GetReleasedGoModMismatches(release config.Release, releasedVersion version.ReleasedVersionInfo) ([]version.GoModMismatch, error)
+}
+
+type bumpPullRequestFinder interface {
+	GetOpenBumpPullRequests(release config.Release) ([]version.PullRequestInfo, error)
 }
 
 type releasesDataProvider struct {
-	githubVersion versionFetcher
-	tasVersion    tasVersionProvider
-	config        config.Config
-	lastFetchTime time.Time
-	cachedData    ReleasesData
+	name             string
+	kind             string
+	platforms        bool
+	title            string
+	subject          string
+	fixedTarget      string
+	githubVersion    versionFetcher
+	tasVersion       tasVersionProvider
+	ciStatus         ciStatusProvider
+	bumpPullRequests bumpPullRequestFinder
+	goMods           goModChecker
+	config           config.Config
+	lastGood         *lastGoodValues
+	invalidated      *invalidations
+	tilesErr         *CellError
+	lastFetchTime    time.Time
+	fetchMux         sync.Mutex
+	cachedData       ReleasesData
 }
 
-func NewReleasesDataProvider(githubVersion versionFetcher, tasVersion tasVersionProvider, cfg config.Config) *releasesDataProvider {
+func NewReleasesDataProvider(githubVersion versionFetcher, tasVersion tasVersionProvider, ciStatus ciStatusProvider, bumpPullRequests bumpPullRequestFinder, cfg config.Config) *releasesDataProvider {
+	goMods, _ := githubVersion.(goModChecker)
 	return &releasesDataProvider{
-		githubVersion: githubVersion,
+		name:             PROVIDER_RELEASES,
+		kind:             KIND_RELEASE,
+		platforms:        true,
+		title:            "Releases",
+		subject:          "Golang",
+		githubVersion:    githubVersion,
+		tasVersion:       tasVersion,
+		ciStatus:         ciStatus,
+		bumpPullRequests: bumpPullRequests,
+		goMods:           goMods,
+		config:           cfg,
+		lastGood:         newLastGoodValues(),
+		invalidated:      newInvalidations(),
+	}
+}
+
+// NewModuleDataProvider reports the version of a Go module required by each
+// release in the same columns as the golang version. Its target is the
+// module version rather than the target golang version. Bump pull requests
+// are only looked up for golang.
+func NewModuleDataProvider(module config.ModuleTarget, moduleVersion versionFetcher, tasVersion tasVersionProvider, ciStatus ciStatusProvider, cfg config.Config) *releasesDataProvider {
+	return &releasesDataProvider{
+		name:          PROVIDER_MODULES + "/" + module.Module,
+		kind:          KIND_MODULE + "/" + module.Module,
+		title:         fmt.Sprintf("%s %s", module.Module, module.Version),
+		subject:       module.Module,
+		fixedTarget:   module.Version,
+		githubVersion: moduleVersion,
 		tasVersion:    tasVersion,
+		ciStatus:      ciStatus,
 		config:        cfg,
+		lastGood:      newLastGoodValues(),
+		invalidated:   newInvalidations(),
 	}
 }
 
 func (p *releasesDataProvider) Get(targetGoVersion string) ReleasesData {
-	if p.lastFetchTime.IsZero() || p.lastFetchTime.Add(FETCH_INTERVAL).Before(time.Now()) {
+	if p.fixedTarget != "" {
+		targetGoVersion = p.fixedTarget
+	}
+	p.fetchMux.Lock()
+	defer p.fetchMux.Unlock()
+	invalidated, all := p.invalidated.take()
+	if all || p.lastFetchTime.IsZero() || p.lastFetchTime.Add(FETCH_INTERVAL).Before(time.Now()) {
 		log.Println("Fetching new data for template")
 		p.lastFetchTime = time.Now()
+		startRefresh(p.name)
+		defer finishRefresh(p.name)
 		p.cachedData = p.fetch(targetGoVersion)
 		return p.cachedData
 	}
+	if len(invalidated) > 0 {
+		startRefresh(p.name)
+		defer finishRefresh(p.name)
+		p.cachedData = p.refetch(invalidated)
+	}
 
 	return p.cachedData
 }
 
+// Invalidate marks the releases of a repository to be refetched on the next
+// Get and reports whether the repository is configured.
+func (p *releasesDataProvider) Invalidate(owner string, repo string) bool {
+	found := false
+	for _, release := range p.releaseLines() {
+		if sameRepo(release.Owner, release.Repo, owner, repo) {
+			p.invalidated.add(release.Name)
+			found = true
+		}
+	}
+	return found
+}
+
+// InvalidateTiles refetches the tile versions and every release on the next
+// Get, since the tile versions affect all of them.
+func (p *releasesDataProvider) InvalidateTiles() {
+	p.tasVersion.Invalidate()
+	p.invalidated.addAll()
+}
+
 func (p *releasesDataProvider) fetch(targetGoVersion string) ReleasesData {
 	data := ReleasesData{
+		Subject:       p.subject,
 		GolangVersion: targetGoVersion,
 	}
 	err := p.tasVersion.Fetch("main")
 	if err != nil {
-		log.Printf("failed to get TAS versions: %s", err.Error())
+		logFailure(p.name, "failed to get TAS versions: %s", err.Error())
 	}
+	_, p.tilesErr = resolveCell(p.lastGood, "tiles", true, err)
 
 	targetGolangV, err := semver.NewVersion(targetGoVersion)
 	if err != nil {
-		log.Printf("failed to parse target golang version: %s", targetGoVersion)
+		logFailure(p.name, "failed to parse target golang version: %s", targetGoVersion)
 	}
 
-	for _, release := range p.config.Releases {
-		devVersion, err := p.githubVersion.GetDevelopVersion(release)
+	if prefetcher, ok := p.githubVersion.(refPrefetcher); ok {
+		err = prefetcher.PrefetchDevelop(p.releaseLines())
 		if err != nil {
-			log.Printf("failed to get develop version for %s: %s", release.Name, err.Error())
+			logFailure(p.name, "failed to prefetch develop versions, falling back to REST: %s", err.Error())
 		}
+		err = prefetcher.PrefetchReleased(p.releaseLines())
+		if err != nil {
+			logFailure(p.name, "failed to prefetch released versions, falling back to REST: %s", err.Error())
+		}
+	}
+	for _, release := range p.releaseLines() {
+		data.Releases = append(data.Releases, p.fetchRelease(release, targetGolangV))
+	}
+	return data
+}
 
-		firstVersionInfo := version.VersionInfo{}
-		bumpedInTas, bumpedInTasw, bumpedInIst := "n/a", "n/a", "n/a"
-		var allBumped bool
-		var releasedVersion string
+// fetchRelease fetches one platform of a release. The CI status and bump pull
+// requests are shared by all platforms and only fetched for the first one.
+func (p *releasesDataProvider) fetchRelease(release config.Release, targetGolangV *semver.Version) Release {
+	errs := map[string]*CellError{}
+	key := release.Name
+	platform := ""
+	platformRows := 1
+	if release.PlatformCount > 1 {
+		platform = release.Platform
+		key += "/" + platform
+		platformRows = 0
+		if release.PlatformIndex == 0 {
+			platformRows = release.PlatformCount
+		}
+	}
+	firstPlatform := release.PlatformIndex == 0
 
-		if release.OnlyDevelop {
-			allBumped = true
-		} else {
-			releasedVersion, err = p.githubVersion.GetReleasedVersion(release)
+	devVersionInfo, err := p.githubVersion.GetDevelopVersion(release)
+	if err != nil {
+		logFailure(p.name, "failed to get develop version for %s: %s", key, err.Error())
+	}
+	goModWarnings := map[string][]string{}
+	if p.goMods != nil && err == nil {
+		goModWarnings[CELL_DEV] = p.goModWarnings(key, func() ([]version.GoModMismatch, error) {
+			return p.goMods.GetDevelopGoModMismatches(release, devVersionInfo.GolangVersion)
+		})
+	}
+	devVersionInfo, errs[CELL_DEV] = resolveCell(p.lastGood, key+"/dev", devVersionInfo, err)
+	devVersion := devVersionInfo.GolangVersion
+	notVendored := map[string]bool{CELL_DEV: devVersionInfo.FromBlobs}
+
+	var bumpPullRequests []version.PullRequestInfo
+	if p.bumpPullRequests != nil && firstPlatform && !isBumped(devVersion, targetGolangV) {
+		bumpPullRequests, err = p.bumpPullRequests.GetOpenBumpPullRequests(release)
+		if err != nil {
+			logFailure(p.name, "failed to get open bump pull requests for %s: %s", release.Name, err.Error())
+		}
+	}
+
+	firstVersionInfo := version.VersionInfo{}
+	bumpedInTas, bumpedInTasw, bumpedInIst := "n/a", "n/a", "n/a"
+	var allBumped bool
+	var releasedVersionInfo version.ReleasedVersionInfo
+
+	if release.OnlyDevelop {
+		allBumped = true
+	} else {
+		releasedVersionInfo, err = p.githubVersion.GetReleasedVersion(release)
+		if err != nil {
+			logFailure(p.name, "failed to get released version for %s: %s", key, err.Error())
+		}
+		if p.goMods != nil && err == nil {
+			goModWarnings[CELL_RELEASED] = p.goModWarnings(key, func() ([]version.GoModMismatch, error) {
+				return p.goMods.GetReleasedGoModMismatches(release, releasedVersionInfo)
+			})
+		}
+		releasedVersionInfo, errs[CELL_RELEASED] = resolveCell(p.lastGood, key+"/released", releasedVersionInfo, err)
+		notVendored[CELL_RELEASED] = releasedVersionInfo.FromBlobs
+
+		if err == nil {
+			firstVersionInfo, err = p.githubVersion.GetFirstReleasedVersion(release, releasedVersionInfo)
 			if err != nil {
-				log.Printf("failed to get released version for %s: %s", release.Name, err.Error())
-			} else {
-				firstVersionInfo, err = p.githubVersion.GetFirstReleasedVersion(release, releasedVersion)
-				if err != nil {
-					log.Printf("failed to get first released minor version for %s: %s", release.Name, err.Error())
-				} else {
-					bumpedInTas, bumpedInTasw, bumpedInIst, allBumped = p.bumpedInTiles(release, firstVersionInfo, targetGolangV)
-				}
+				logFailure(p.name, "failed to get first released minor version for %s: %s", key, err.Error())
+			}
+		}
+		firstVersionInfo, errs[CELL_FIRST_RELEASED] = resolveCell(p.lastGood, key+"/first-released", firstVersionInfo, err)
+		if firstVersionInfo.GolangVersion != "" {
+			bumpedInTas, bumpedInTasw, bumpedInIst, allBumped = p.bumpedInTiles(release, firstVersionInfo, targetGolangV)
+			if release.TasReleaseName != "" || release.TaswReleaseName != "" || release.IstReleaseName != "" {
+				errs[CELL_TILES] = p.tilesErr
 			}
 		}
+	}
 
-		data.Releases = append(data.Releases, Release{
-			Name:                        release.Name,
-			URL:                         release.URL,
-			CIURL:                       p.config.CIURL(release),
-			CIBadgeURL:                  "/images/concourse-icon.png",
-			VersionOnDev:                devVersion,
-			ReleasedVersion:             releasedVersion,
-			FirstReleasedGolangVersion:  firstVersionInfo.GolangVersion,
-			FirstReleasedReleaseVersion: firstVersionInfo.ReleaseVersion,
-			BumpedInTas:                 bumpedInTas,
-			BumpedInTasw:                bumpedInTasw,
-			BumpedInIst:                 bumpedInIst,
-			AllBumped:                   allBumped,
-		})
+	var ciInfo CIInfo
+	if firstPlatform {
+		ciInfo = getCIInfo(p.name, p.ciStatus, release.Name, release.CI)
+	}
+
+	return Release{
+		Name:                        release.Name,
+		URL:                         release.URL,
+		Team:                        ciTeam(release.CI),
+		Platform:                    platform,
+		PlatformRows:                platformRows,
+		DevelopBranch:               release.DevelopBranch,
+		CI:                          ciInfo,
+		BumpPullRequests:            bumpPullRequests,
+		VersionOnDev:                devVersion,
+		ReleasedVersion:             releasedVersionInfo.GolangVersion,
+		ReleasedTag:                 releasedVersionInfo.Tag,
+		ReleasedTagReason:           releasedVersionInfo.Reason,
+		FirstReleasedGolangVersion:  firstVersionInfo.GolangVersion,
+		FirstReleasedReleaseVersion: firstVersionInfo.ReleaseVersion,
+		FirstReleasedTag:            firstVersionInfo.Tag,
+		FirstReleasedAt:             formatDate(firstVersionInfo.PublishedAt),
+		FirstPatchGolangVersion:     firstVersionInfo.PatchGolangVersion,
+		FirstPatchReleaseVersion:    firstVersionInfo.PatchReleaseVersion,
+		FirstPatchReleasedAt:        formatDate(firstVersionInfo.PatchPublishedAt),
+		BumpedInTas:                 bumpedInTas,
+		BumpedInTasw:                bumpedInTasw,
+		BumpedInIst:                 bumpedInIst,
+		AllBumped:                   allBumped,
+		Errors:                      errs,
+		GoModWarnings:               goModWarnings,
+		NotVendored:                 notVendored,
+	}
+}
+
+// goModWarnings describes the go.mod files that ask for a newer golang than
+// the release packages. A failed check only logs, since the warnings are
+// advisory.
+func (p *releasesDataProvider) goModWarnings(key string, check func() ([]version.GoModMismatch, error)) []string {
+	mismatches, err := check()
+	if err != nil {
+		logFailure(p.name, "failed to check go.mod files for %s: %s", key, err.Error())
+		return nil
+	}
+	var warnings []string
+	for _, mismatch := range mismatches {
+		warnings = append(warnings, mismatch.String())
+	}
+	return warnings
+}
+
+// refetch fetches the invalidated releases again and keeps the other rows.
+func (p *releasesDataProvider) refetch(invalidated map[string]bool) ReleasesData {
+	data := p.cachedData
+	data.Releases = append([]Release(nil), p.cachedData.Releases...)
+	targetGolangV, _ := semver.NewVersion(data.GolangVersion)
+	for i, release := range p.releaseLines() {
+		if invalidated[release.Name] && i < len(data.Releases) {
+			data.Releases[i] = p.fetchRelease(release, targetGolangV)
+		}
 	}
 	return data
 }
 
+func (p *releasesDataProvider) Kind() string {
+	return p.kind
+}
+
+func (p *releasesDataProvider) Title() string {
+	return p.title
+}
+
+func (p *releasesDataProvider) Rows(targetGoVersion string) []artifact.Row {
+	data := p.Get(targetGoVersion)
+	targetGolangV, _ := semver.NewVersion(data.GolangVersion)
+
+	var rows []artifact.Row
+	for _, release := range data.Releases {
+		stages := []artifact.Stage{{
+			Name:        artifact.STAGE_DEVELOP,
+			Platform:    release.Platform,
+			Version:     release.VersionOnDev,
+			URL:         branchURL(release.URL, release.DevelopBranch),
+			Bumped:      isBumped(release.VersionOnDev, targetGolangV),
+			NotVendored: release.NotVendored[CELL_DEV],
+			Error:       release.Errors[CELL_DEV],
+		}}
+		if release.ReleasedVersion != "" || release.Errors[CELL_RELEASED] != nil {
+			stages = append(stages, artifact.Stage{
+				Name:        artifact.STAGE_RELEASED,
+				Platform:    release.Platform,
+				Version:     release.ReleasedVersion,
+				URL:         tagURL(release.URL, release.ReleasedTag),
+				Bumped:      isBumped(release.ReleasedVersion, targetGolangV),
+				NotVendored: release.NotVendored[CELL_RELEASED],
+				Error:       release.Errors[CELL_RELEASED],
+			})
+		}
+		for _, tile := range []struct{ name, bumpedIn, file string }{
+			{"TAS", release.BumpedInTas, version.TAS_RELEASES_FILE},
+			{"TASW", release.BumpedInTasw, version.TASW_RELEASES_FILE},
+			{"IST", release.BumpedInIst, version.IST_RELEASES_FILE},
+		} {
+			if tile.bumpedIn == "n/a" {
+				continue
+			}
+			stages = append(stages, artifact.Stage{
+				Name:    tile.name,
+				Version: tile.bumpedIn,
+				URL:     p.tasVersion.GetKilnfileChangeURL(tile.file),
+				Bumped:  strings.HasPrefix(tile.bumpedIn, "yes"),
+				Tile:    true,
+				Error:   release.Errors[CELL_TILES],
+			})
+		}
+
+		warnings := append(append([]string(nil), release.GoModWarnings[CELL_DEV]...), release.GoModWarnings[CELL_RELEASED]...)
+
+		// the other platforms of a release extend the row of the first one
+		if release.PlatformRows == 0 && len(rows) > 0 {
+			row := &rows[len(rows)-1]
+			row.Stages = append(row.Stages, stages...)
+			row.Warnings = appendMissing(row.Warnings, warnings)
+			row.AllBumped = row.AllBumped && release.AllBumped
+			row.Status = artifact.Status(row.AllBumped, row.Stages)
+			continue
+		}
+
+		links := ciLinks(release.CI)
+		for _, pullRequest := range release.BumpPullRequests {
+			links = append(links, artifact.Link{Title: fmt.Sprintf("#%d", pullRequest.Number), URL: pullRequest.URL})
+		}
+		rows = append(rows, artifact.Row{
+			Kind:      p.kind,
+			Name:      release.Name,
+			URL:       release.URL,
+			Team:      release.Team,
+			Links:     links,
+			Stages:    stages,
+			Status:    artifact.Status(release.AllBumped, stages),
+			AllBumped: release.AllBumped,
+			Warnings:  warnings,
+		})
+	}
+	return rows
+}
+
+// appendMissing appends the values that are not in the slice yet, since the
+// platforms of a release share their go.mod files.
+func appendMissing(values []string, more []string) []string {
+	for _, value := range more {
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (p *releasesDataProvider) releaseLines() []config.Release {
+	var releases []config.Release
+	for _, release := range p.config.Releases {
+		for _, line := range release.Lines() {
+			if p.platforms {
+				releases = append(releases, line.PlatformReleases()...)
+			} else {
+				releases = append(releases, line.WithoutPlatforms())
+			}
+		}
+	}
+	return releases
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+func isBumped(golangVersion string, targetGolangV *semver.Version) bool {
+	if targetGolangV == nil {
+		return false
+	}
+	golangV, err := semver.NewVersion(golangVersion)
+	if err != nil {
+		return false
+	}
+	return !targetGolangV.GreaterThan(golangV)
+}
+
 func (p *releasesDataProvider) bumpedInTiles(release config.Release, firstVersionInfo version.VersionInfo, targetGolangV *semver.Version) (string, string, string, bool) {
 	var bumpedInTas, bumpedInTasw, bumpedInIst string
 	var allBumped bool
 
 	firstReleaseV, err := semver.NewVersion(firstVersionInfo.ReleaseVersion)
 	if err != nil {
-		log.Printf("failed to parse first release version for %s: %s", release.Name, err.Error())
+		logFailure(p.name, "failed to parse first release version for %s: %s", release.Name, err.Error())
 		return bumpedInTas, bumpedInTasw, bumpedInIst, allBumped
 	}
 
 	firstGolangVersion, err := semver.NewVersion(firstVersionInfo.GolangVersion)
 	if err != nil {
-		log.Printf("failed to parse first golang version for %s: %s", release.Name, err.Error())
+		logFailure(p.name, "failed to parse first golang version for %s: %s", release.Name, err.Error())
 		return bumpedInTas, bumpedInTasw, bumpedInIst, allBumped
 	}
 
@@ -180,16 +533,16 @@ func (p *releasesDataProvider) getTileBumpInfo(tileName string, releaseName stri
 	case "IST":
 		tileReleaseVersion, found = p.tasVersion.GetIstReleaseVersion(releaseName)
 	default:
-		log.Printf("unsupported tile name provided: %s", tileName)
+		logFailure(p.name, "unsupported tile name provided: %s", tileName)
 		return "", false
 	}
 	if !found {
-		log.Printf("failed to find %s release version for %s", tileName, releaseName)
+		logFailure(p.name, "failed to find %s release version for %s", tileName, releaseName)
 		return "", false
 	}
 	tasReleaseV, err := semver.NewVersion(tileReleaseVersion)
 	if err != nil {
-		log.Printf("failed to parse TAS release version for %s: %s", releaseName, err.Error())
+		logFailure(p.name, "failed to parse TAS release version for %s: %s", releaseName, err.Error())
 		return "", false
 	}
 
@@ -198,3 +551,19 @@ func (p *releasesDataProvider) getTileBumpInfo(tileName string, releaseName stri
 	}
 	return fmt.Sprintf("yes (%s)", tasReleaseV), true
 }
+
+// branchURL links to the commits on a branch of a GitHub repository.
+func branchURL(repoURL string, branch string) string {
+	if branch == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/commits/%s", strings.TrimSuffix(repoURL, "/"), branch)
+}
+
+// tagURL links to the GitHub release of a tag.
+func tagURL(repoURL string, tag string) string {
+	if tag == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/releases/tag/%s", strings.TrimSuffix(repoURL, "/"), tag)
+}
@@ -0,0 +1,179 @@
+package dataprovider
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/version"
+)
+
+const (
+	SUPPORT_CURRENT     = "current"
+	SUPPORT_PREVIOUS    = "previous"
+	SUPPORT_UNSUPPORTED = "unsupported"
+	SUPPORT_UNKNOWN     = "unknown"
+)
+
+var (
+	supportRank = map[string]int{
+		SUPPORT_UNSUPPORTED: 0,
+		SUPPORT_PREVIOUS:    1,
+		SUPPORT_CURRENT:     2,
+		SUPPORT_UNKNOWN:     3,
+	}
+)
+
+type artifactRegistry interface {
+	Providers() []artifact.Provider
+}
+
+type goCalendar interface {
+	Minor(golangVersion string) (version.GoMinor, bool)
+	Minors(now time.Time) []version.GoMinor
+}
+
+type SupportStage struct {
+	Name    string
+	Version string
+	Class   string
+}
+
+type SupportRow struct {
+	Kind   string
+	Title  string
+	Name   string
+	URL    string
+	Stages []SupportStage
+	Class  string
+}
+
+type SupportMatrixData struct {
+	Current  version.GoMinor
+	Previous version.GoMinor
+	Rows     []SupportRow
+	Counts   map[string]int
+}
+
+type supportMatrixProvider struct {
+	base     *baseDataProvider
+	registry artifactRegistry
+	calendar goCalendar
+}
+
+func NewSupportMatrixProvider(base *baseDataProvider, registry artifactRegistry, calendar goCalendar) *supportMatrixProvider {
+	return &supportMatrixProvider{
+		base:     base,
+		registry: registry,
+		calendar: calendar,
+	}
+}
+
+// Get classifies every artifact by the Go minor it is on. The current minor
+// is the newest one in the calendar or the campaign target, whichever is
+// newer, and the minor before it is the previous supported one. A row is as
+// supported as its least supported stage. Module rows track module versions
+// rather than Go and are left out.
+func (p *supportMatrixProvider) Get(showTiles bool) SupportMatrixData {
+	targetGoVersion := p.base.Get().TargetGoVersion
+	current, previous := p.supportedMinors(targetGoVersion)
+	data := SupportMatrixData{
+		Current:  current,
+		Previous: previous,
+		Counts:   map[string]int{},
+	}
+	currentV, err := semver.NewVersion(current.Version)
+	if err != nil {
+		logFailure(PROVIDER_BASE, "failed to determine the current golang minor: %s", err.Error())
+		return data
+	}
+
+	for _, provider := range p.registry.Providers() {
+		if strings.HasPrefix(provider.Kind(), KIND_MODULE+"/") {
+			continue
+		}
+		for _, row := range provider.Rows(targetGoVersion) {
+			if !showTiles {
+				row = row.WithoutTiles()
+			}
+			supportRow := SupportRow{
+				Kind:  row.Kind,
+				Title: provider.Title(),
+				Name:  row.Name,
+				URL:   row.URL,
+				Class: SUPPORT_UNKNOWN,
+			}
+			for _, stage := range row.Stages {
+				class := classifySupport(stage.Version, currentV)
+				supportRow.Stages = append(supportRow.Stages, SupportStage{Name: stage.Label(), Version: stage.Version, Class: class})
+				if supportRank[class] < supportRank[supportRow.Class] {
+					supportRow.Class = class
+				}
+			}
+			data.Counts[supportRow.Class]++
+			data.Rows = append(data.Rows, supportRow)
+		}
+	}
+	return data
+}
+
+// supportedMinors looks the target up in the calendar first, which logs when
+// the calendar is out of date.
+func (p *supportMatrixProvider) supportedMinors(targetGoVersion string) (version.GoMinor, version.GoMinor) {
+	p.calendar.Minor(targetGoVersion)
+	minors := p.calendar.Minors(time.Now())
+	byVersion := map[string]version.GoMinor{}
+	for _, minor := range minors {
+		byVersion[minor.Version] = minor
+	}
+
+	newest := targetGoVersion
+	if len(minors) > 0 {
+		newest = minors[0].Version
+		targetV, err := semver.NewVersion(targetGoVersion)
+		newestV, newestErr := semver.NewVersion(newest)
+		if err == nil && newestErr == nil && targetV.GreaterThan(newestV) {
+			newest = targetGoVersion
+		}
+	}
+	newestV, err := semver.NewVersion(newest)
+	if err != nil {
+		return version.GoMinor{Version: newest}, version.GoMinor{}
+	}
+
+	current := minorOrVersion(byVersion, fmt.Sprintf("%d.%d", newestV.Major(), newestV.Minor()))
+	if newestV.Minor() == 0 {
+		return current, version.GoMinor{}
+	}
+	previous := minorOrVersion(byVersion, fmt.Sprintf("%d.%d", newestV.Major(), newestV.Minor()-1))
+	return current, previous
+}
+
+func minorOrVersion(byVersion map[string]version.GoMinor, minorVersion string) version.GoMinor {
+	if minor, ok := byVersion[minorVersion]; ok {
+		return minor
+	}
+	return version.GoMinor{Version: minorVersion}
+}
+
+// classifySupport compares the minor of a version with the current minor.
+// Versions newer than the current minor, such as release candidates, count
+// as current.
+func classifySupport(goVersion string, currentV *semver.Version) string {
+	if goVersion == "" {
+		return SUPPORT_UNKNOWN
+	}
+	goV, err := semver.NewVersion(goVersion)
+	if err != nil || goV.Major() != currentV.Major() {
+		return SUPPORT_UNKNOWN
+	}
+	switch {
+	case goV.Minor() >= currentV.Minor():
+		return SUPPORT_CURRENT
+	case goV.Minor()+1 == currentV.Minor():
+		return SUPPORT_PREVIOUS
+	}
+	return SUPPORT_UNSUPPORTED
+}
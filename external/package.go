@@ -0,0 +1 @@
+package external // import "github.com/cloudfoundry-incubator/golang-bump-progress/external"
@@ -0,0 +1,221 @@
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/dataprovider"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/metrics"
+)
+
+const (
+	KIND_PREFIX    = "external/"
+	MAX_STDERR_LOG = 4096
+	MAX_STDOUT     = 10 << 20
+	WAIT_DELAY     = 5 * time.Second
+)
+
+var errStdoutTooLarge = fmt.Errorf("output is larger than %d bytes", MAX_STDOUT)
+
+// Output is what a provider executable prints on stdout. Kind and status of
+// the rows are filled in by the app when left empty.
+type Output struct {
+	Rows []artifact.Row `json:"rows"`
+}
+
+// provider runs an executable at most once per FETCH_INTERVAL. The app's
+// target version is written to its stdin followed by a newline and an Output
+// is read from its stdout. Other targets have no rows, so callers cannot
+// make it run on demand. A failing run keeps the previous rows, with the
+// failure attached to every stage, or a single row describing it when there
+// are none. Once there are rows, runs happen in the background and callers
+// get the cached rows meanwhile.
+type provider struct {
+	config        config.ExternalProvider
+	ctx           context.Context
+	target        func() string
+	lastFetchTime time.Time
+	lastTarget    string
+	lastGood      time.Time
+	running       bool
+	fetchMux      sync.Mutex
+	cachedRows    []artifact.Row
+}
+
+func NewProvider(ctx context.Context, cfg config.ExternalProvider, target func() string) *provider {
+	return &provider{
+		config: cfg,
+		ctx:    ctx,
+		target: target,
+	}
+}
+
+func (p *provider) Kind() string {
+	return KIND_PREFIX + p.config.Name
+}
+
+func (p *provider) Title() string {
+	return p.config.Title
+}
+
+func (p *provider) Rows(targetVersion string) []artifact.Row {
+	target := p.target()
+	if targetVersion != "" && targetVersion != target {
+		return nil
+	}
+
+	p.fetchMux.Lock()
+	defer p.fetchMux.Unlock()
+	due := p.lastFetchTime.IsZero() || p.lastFetchTime.Add(dataprovider.FETCH_INTERVAL).Before(time.Now()) || p.lastTarget != target
+	if due && !p.running {
+		p.lastFetchTime = time.Now()
+		p.running = true
+		if p.cachedRows == nil {
+			p.fetchMux.Unlock()
+			p.refresh(target)
+			p.fetchMux.Lock()
+		} else {
+			go p.refresh(target)
+		}
+	}
+	return p.cachedRows
+}
+
+func (p *provider) refresh(targetVersion string) {
+	started := time.Now()
+	defer func() {
+		metrics.RefreshTotal.Inc(p.Kind())
+		metrics.RefreshDuration.Set(time.Since(started).Seconds(), p.Kind())
+	}()
+
+	rows, err := p.run(targetVersion)
+
+	p.fetchMux.Lock()
+	defer p.fetchMux.Unlock()
+	p.running = false
+	if err != nil {
+		metrics.RefreshFailures.Inc(p.Kind())
+		log.Printf("failed to run external provider %s: %s", p.config.Name, err.Error())
+		if p.lastTarget != targetVersion {
+			p.cachedRows = nil
+		}
+		p.lastTarget = targetVersion
+		p.cachedRows = p.staleRows(err)
+		return
+	}
+	p.lastTarget = targetVersion
+	p.cachedRows = rows
+	p.lastGood = time.Now()
+}
+
+func (p *provider) run(targetVersion string) ([]artifact.Row, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, p.config.ParsedTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	stdout := &cappedBuffer{max: MAX_STDOUT}
+	cmd := exec.CommandContext(ctx, p.config.Command, p.config.Args...)
+	cmd.Stdin = strings.NewReader(targetVersion + "\n")
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = WAIT_DELAY
+
+	err := cmd.Run()
+	if stderr.Len() > 0 {
+		log.Printf("external provider %s stderr: %s", p.config.Name, lastBytes(stderr.String(), MAX_STDERR_LOG))
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("timed out after %s: %w", p.config.ParsedTimeout, context.DeadlineExceeded)
+	}
+	if stdout.exceeded {
+		return nil, errStdoutTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var output Output
+	err = json.Unmarshal(stdout.buf.Bytes(), &output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse output: %w", err)
+	}
+	for i, row := range output.Rows {
+		if row.Name == "" {
+			return nil, fmt.Errorf("row %d has no name", i)
+		}
+		output.Rows[i].Kind = p.Kind()
+		if row.Status == "" {
+			output.Rows[i].Status = artifact.Status(row.AllBumped, row.Stages)
+		}
+	}
+	return output.Rows, nil
+}
+
+// staleRows marks every stage of the previous rows with the failure.
+func (p *provider) staleRows(err error) []artifact.Row {
+	cellErr := &artifact.CellError{
+		Kind:        dataprovider.ERROR_UNKNOWN,
+		Explanation: "external provider failed",
+		Message:     err.Error(),
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		cellErr.Kind = dataprovider.ERROR_TIMEOUT
+		cellErr.Explanation = "external provider timed out"
+	}
+	if len(p.cachedRows) == 0 {
+		stages := []artifact.Stage{{Name: "run", Error: cellErr}}
+		return []artifact.Row{{
+			Kind:   p.Kind(),
+			Name:   p.config.Title,
+			Stages: stages,
+			Status: artifact.Status(false, stages),
+		}}
+	}
+	cellErr.StaleFor = dataprovider.FormatAge(time.Since(p.lastGood))
+
+	var rows []artifact.Row
+	for _, row := range p.cachedRows {
+		stages := make([]artifact.Stage, len(row.Stages))
+		for i, stage := range row.Stages {
+			stage.Error = cellErr
+			stages[i] = stage
+		}
+		row.Stages = stages
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// cappedBuffer fails writes past max bytes, which stops the copy of the
+// executable's output. The buffer is not embedded, since its ReadFrom would
+// bypass the cap.
+type cappedBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	exceeded bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.max {
+		b.exceeded = true
+		return 0, errStdoutTooLarge
+	}
+	return b.buf.Write(p)
+}
+
+func lastBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
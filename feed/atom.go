@@ -0,0 +1,128 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/auth"
+)
+
+const (
+	FEED_PATH     = "/feed.atom"
+	FEED_AUTHOR   = "golang-bump-progress"
+	ATOM_MIMETYPE = "application/atom+xml; charset=utf-8"
+)
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type eventSource interface {
+	Events(team string, showTiles bool) []Event
+}
+
+// handler serves the events as an Atom feed, optionally filtered with
+// ?team=. Tile events are only included for authorized callers.
+type handler struct {
+	events eventSource
+}
+
+func NewHandler(events eventSource) *handler {
+	return &handler{events: events}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	team := r.URL.Query().Get("team")
+	events := h.events.Events(team, auth.FromRequest(r).Authorized)
+
+	selfURL := requestBaseURL(r) + FEED_PATH
+	title := "Golang bump progress"
+	if team != "" {
+		selfURL += "?team=" + url.QueryEscape(team)
+		title = fmt.Sprintf("Golang bump progress of %s", team)
+	}
+	feed := atomFeed{
+		ID:      selfURL,
+		Title:   title,
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: selfURL, Rel: "self"},
+			{Href: requestBaseURL(r) + "/", Rel: "alternate"},
+		},
+		Author: atomAuthor{Name: FEED_AUTHOR},
+	}
+	if len(events) > 0 {
+		feed.Updated = events[0].Observed.Format(time.RFC3339)
+	}
+	for _, event := range events {
+		entry := atomEntry{
+			ID:      event.ID,
+			Title:   event.Title(),
+			Updated: event.Observed.Format(time.RFC3339),
+			Categories: []atomCategory{
+				{Term: event.Type},
+				{Term: event.Kind},
+				{Term: event.Stage},
+			},
+			Summary: fmt.Sprintf("%s %s, %s: %s", event.Kind, event.Name, event.Stage, event.Version),
+		}
+		if event.URL != "" {
+			entry.Links = append(entry.Links, atomLink{Href: event.URL, Rel: "alternate"})
+		}
+		if event.Team != "" {
+			entry.Categories = append(entry.Categories, atomCategory{Term: "team:" + event.Team})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	w.Header().Set("Content-Type", ATOM_MIMETYPE)
+	_, err := w.Write([]byte(xml.Header))
+	if err == nil {
+		err = xml.NewEncoder(w).Encode(feed)
+	}
+	if err != nil {
+		log.Printf("failed to write feed: %s", err.Error())
+	}
+}
+
+// requestBaseURL reconstructs the external URL of the app, honoring the
+// scheme set by a TLS-terminating proxy.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
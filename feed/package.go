@@ -0,0 +1 @@
+package feed // import "github.com/cloudfoundry-incubator/golang-bump-progress/feed"
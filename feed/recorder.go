@@ -0,0 +1,179 @@
+package feed
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/dataprovider"
+)
+
+const (
+	OBSERVE_INTERVAL = 5 * time.Minute
+	MAX_EVENTS       = 500
+	STATE_CACHE_KEY  = "feed/state"
+
+	EVENT_BUMPED    = "bumped"
+	EVENT_REGRESSED = "regressed"
+)
+
+type stateCache interface {
+	Get(key string, value interface{}) bool
+	Set(key string, value interface{}) error
+}
+
+// Event is an observed change of a stage between bumped and not bumped.
+type Event struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Target   string    `json:"target"`
+	Kind     string    `json:"kind"`
+	Name     string    `json:"name"`
+	Team     string    `json:"team,omitempty"`
+	Stage    string    `json:"stage"`
+	Platform string    `json:"platform,omitempty"`
+	Tile     bool      `json:"tile,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Observed time.Time `json:"observed"`
+}
+
+// Title describes the event in a feed entry.
+func (e Event) Title() string {
+	if e.Platform != "" {
+		return fmt.Sprintf("%s on %s", e.title(), e.Platform)
+	}
+	return e.title()
+}
+
+func (e Event) title() string {
+	switch {
+	case e.Type == EVENT_REGRESSED:
+		return fmt.Sprintf("%s: %s no longer on golang %s (%s)", e.Name, e.Stage, e.Target, e.Version)
+	case e.Tile:
+		return fmt.Sprintf("%s: shipped golang %s in %s", e.Name, e.Target, e.Stage)
+	case e.Stage == artifact.STAGE_DEVELOP:
+		return fmt.Sprintf("%s: develop bumped to golang %s", e.Name, e.Version)
+	case e.Stage == artifact.STAGE_RELEASED:
+		return fmt.Sprintf("%s: released with golang %s", e.Name, e.Version)
+	}
+	return fmt.Sprintf("%s: %s bumped to golang %s", e.Name, e.Stage, e.Version)
+}
+
+type state struct {
+	Target string          `json:"target"`
+	Bumped map[string]bool `json:"bumped"`
+	Events []Event         `json:"events"`
+}
+
+// recorder turns the rows observed on every refresh into events. The first
+// observation of a target only records the baseline, and stages that failed
+// to refresh keep their previous state. The state is persisted so that
+// changes made while the app was down are still reported after a restart.
+type recorder struct {
+	cache stateCache
+	state state
+	mux   sync.Mutex
+}
+
+func NewRecorder(cache stateCache) *recorder {
+	r := &recorder{cache: cache}
+	if !cache.Get(STATE_CACHE_KEY, &r.state) || r.state.Bumped == nil {
+		r.state = state{Bumped: map[string]bool{}}
+	}
+	return r
+}
+
+// Observe compares the rows of releases, images, plugins and other Go
+// artifacts with the previous observation. Module rows are ignored.
+func (r *recorder) Observe(target string, rows []artifact.Row, now time.Time) {
+	if target == "" {
+		return
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	baseline := r.state.Target != target
+	if baseline {
+		r.state.Target = target
+		r.state.Bumped = map[string]bool{}
+	}
+	changed := baseline
+	for _, row := range rows {
+		if strings.HasPrefix(row.Kind, dataprovider.KIND_MODULE+"/") {
+			continue
+		}
+		for _, stage := range row.Stages {
+			if stage.Error != nil {
+				continue
+			}
+			key := row.Kind + "/" + row.Name + "/" + stage.Label()
+			wasBumped, seen := r.state.Bumped[key]
+			if seen && wasBumped == stage.Bumped {
+				continue
+			}
+			r.state.Bumped[key] = stage.Bumped
+			changed = true
+			if !seen || baseline {
+				continue
+			}
+			eventType := EVENT_BUMPED
+			if !stage.Bumped {
+				eventType = EVENT_REGRESSED
+			}
+			r.addEvent(Event{
+				Type:     eventType,
+				Target:   target,
+				Kind:     row.Kind,
+				Name:     row.Name,
+				Team:     row.Team,
+				Stage:    stage.Name,
+				Platform: stage.Platform,
+				Tile:     stage.Tile,
+				Version:  stage.Version,
+				URL:      stage.URL,
+				Observed: now.UTC(),
+			})
+		}
+	}
+
+	if changed {
+		err := r.cache.Set(STATE_CACHE_KEY, r.state)
+		if err != nil {
+			log.Printf("failed to persist feed state: %s", err.Error())
+		}
+	}
+}
+
+// addEvent prepends the event. Its ID is derived from its content and the
+// time it was observed, so it stays the same when the feed is served again.
+func (r *recorder) addEvent(event Event) {
+	sum := sha1.Sum([]byte(strings.Join([]string{event.Target, event.Kind, event.Name, event.Stage, event.Platform, event.Type, event.Version, event.Observed.Format(time.RFC3339)}, "\x00")))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	event.ID = fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+
+	r.state.Events = append([]Event{event}, r.state.Events...)
+	if len(r.state.Events) > MAX_EVENTS {
+		r.state.Events = r.state.Events[:MAX_EVENTS]
+	}
+}
+
+// Events returns the events of a team, or of all teams when team is empty,
+// newest first.
+func (r *recorder) Events(team string, showTiles bool) []Event {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	var events []Event
+	for _, event := range r.state.Events {
+		if (team != "" && event.Team != team) || (event.Tile && !showTiles) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
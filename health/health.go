@@ -0,0 +1,85 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+type Check func() error
+
+type CheckResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Ready   bool          `json:"ready"`
+	Checks  []CheckResult `json:"checks"`
+	Details interface{}   `json:"details,omitempty"`
+}
+
+type health struct {
+	checks  []namedCheck
+	details func() interface{}
+	mux     sync.Mutex
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+func NewHealth() *health {
+	return &health{}
+}
+
+func (h *health) AddCheck(name string, check Check) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// SetDetails registers extra state to include in readiness reports.
+func (h *health) SetDetails(details func() interface{}) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.details = details
+}
+
+func (h *health) Report() Report {
+	h.mux.Lock()
+	checks := append([]namedCheck{}, h.checks...)
+	details := h.details
+	h.mux.Unlock()
+
+	report := Report{Ready: true}
+	for _, c := range checks {
+		result := CheckResult{Name: c.name, OK: true}
+		if err := c.check(); err != nil {
+			result.OK = false
+			result.Error = err.Error()
+			report.Ready = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	if details != nil {
+		report.Details = details()
+	}
+	return report
+}
+
+func (h *health) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok\n"))
+}
+
+func (h *health) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := h.Report()
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
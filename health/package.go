@@ -0,0 +1 @@
+package health // import "github.com/cloudfoundry-incubator/golang-bump-progress/health"
@@ -1,76 +1,477 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/auth"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/badge"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/ci"
 	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
 	"github.com/cloudfoundry-incubator/golang-bump-progress/dataprovider"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/external"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/feed"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/health"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/metrics"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/tracking"
 	"github.com/cloudfoundry-incubator/golang-bump-progress/version"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/webhook"
 	"github.com/google/go-github/v54/github"
 	"golang.org/x/oauth2"
 )
 
+type section struct {
+	Kind  string
+	Title string
+}
+
+type baseView struct {
+	dataprovider.BaseData
+	Sections []section
+	Campaign *config.Campaign
+	User     string
+	LoginURL string
+}
+
+// Deadlines of the table views are keyed by row name. Bumped is keyed the
+// same way and leaves out tile stages unless ShowTiles is set.
+type releasesView struct {
+	dataprovider.ReleasesData
+	ShowTiles bool
+	Bumped    map[string]bool
+	Deadlines map[string]*artifact.Deadline
+}
+
+type imagesView struct {
+	dataprovider.ImagesData
+	Deadlines map[string]*artifact.Deadline
+}
+
+type pluginsView struct {
+	dataprovider.PluginsData
+	Deadlines map[string]*artifact.Deadline
+}
+
+type buildpacksView struct {
+	dataprovider.BuildpacksData
+	Deadlines map[string]*artifact.Deadline
+}
+
+type artifactsView struct {
+	Title string
+	Rows  []artifact.Row
+}
+
+// tableHandler renders the table of one artifact kind from the provider's
+// data and the rows visible to the caller. Kinds without one are rendered
+// with the generic artifacts table.
+type tableHandler func(w http.ResponseWriter, r *http.Request, targetGoVersion string, rows []artifact.Row)
+
+const (
+	DEFAULT_PORT        = "8080"
+	DEFAULT_GO_CALENDAR = "golang_releases.json"
+	READ_TIMEOUT        = 30 * time.Second
+	WRITE_TIMEOUT       = 5 * time.Minute
+	IDLE_TIMEOUT        = 2 * time.Minute
+	SHUTDOWN_TIMEOUT    = 30 * time.Second
+	WARMUP_RETRY_DELAY  = 30 * time.Second
+)
+
 func main() {
+	listenAddr := flag.String("listen", defaultListenAddr(), "address to listen on, defaults to :$PORT")
+	configPath := flag.String("config", "config.json", "path to the config file")
+	flag.Parse()
+
 	baseTmpl := template.Must(template.ParseFiles("templates/base.html"))
-	releasesTableTmpl := template.Must(template.ParseFiles("templates/releases_table.html"))
-	imagesTableTmpl := template.Must(template.ParseFiles("templates/images_table.html"))
-	pluginsTableTmpl := template.Must(template.ParseFiles("templates/plugins_table.html"))
-	cfg, err := config.LoadConfig("config.json")
+	artifactsTableTmpl := parseTableTemplate("templates/artifacts_table.html")
+	releasesTableTmpl := parseTableTemplate("templates/releases_table.html")
+	imagesTableTmpl := parseTableTemplate("templates/images_table.html")
+	pluginsTableTmpl := parseTableTemplate("templates/plugins_table.html")
+	buildpacksTableTmpl := parseTableTemplate("templates/buildpacks_table.html")
+	supportMatrixTmpl := template.Must(template.ParseFiles("templates/support_matrix.html"))
+	badgeTmpl := template.Must(template.ParseFiles("templates/badge.svg"))
+	cfg, err := config.LoadConfig(*configPath)
 	if err != nil {
 		log.Fatalf("failed to load config: %s", err.Error())
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
 	githubToken := os.Getenv("GITHUB_TOKEN")
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: githubToken},
 	)
-	ctx := context.Background()
 	tc := oauth2.NewClient(ctx, ts)
+	tc.Transport = metrics.NewTransport("github", tc.Transport)
 	githubClient := github.NewClient(tc)
 	boshPackageVersion := version.NewBoshPackageVersion(ctx, githubClient)
-	err = boshPackageVersion.PopulateCache()
+	go warmUp(ctx, boshPackageVersion)
+
+	goCalendar, err := version.LoadGoCalendar(goCalendarPath())
 	if err != nil {
-		log.Fatalf("failed to warm up cache: %s", err.Error())
+		log.Fatalf("failed to load go release calendar: %s", err.Error())
 	}
 
-	githubVersion := version.NewGithubVersion(ctx, githubClient, boshPackageVersion)
+	persistentCache, err := version.NewPersistentCache(os.Getenv("CACHE_FILE"))
+	if err != nil {
+		log.Fatalf("failed to load cache: %s", err.Error())
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(version.CACHE_FLUSH_INTERVAL):
+			}
+			err := persistentCache.Flush()
+			if err != nil {
+				log.Printf("failed to write cache: %s", err.Error())
+			}
+		}
+	}()
+	// the GraphQL API requires authentication
+	var graphqlHTTPClient *http.Client
+	if githubToken != "" {
+		graphqlHTTPClient = tc
+	}
+	githubVersion := version.NewGraphqlVersion(ctx, githubClient, graphqlHTTPClient, boshPackageVersion, persistentCache)
 	tasVersion := version.NewTasVersion(ctx, githubClient)
+	ciProviders := ci.NewProviders(map[string]ci.Provider{
+		config.CI_PROVIDER_CONCOURSE:      ci.NewConcourseProvider(cfg.CIBaseURL, ci.NewConcourseClient(cfg.CIBaseURL, os.Getenv("CONCOURSE_TOKEN"))),
+		config.CI_PROVIDER_GITHUB_ACTIONS: ci.NewGithubActionsProvider(ctx, githubClient),
+	})
+	bumpPullRequests := version.NewBumpPullRequests(ctx, githubClient, cfg.BumpPRTitleRegexp)
 	baseDataProvider := dataprovider.NewBaseDataProvider(ctx, githubClient)
-	releasesDataProvider := dataprovider.NewReleasesDataProvider(githubVersion, tasVersion, cfg)
-	imagesDataProvider := dataprovider.NewImagesDataProvider(cfg)
-	pluginsDataProvider := dataprovider.NewPluginsDataProvider(ctx, githubClient, cfg)
+	releasesDataProvider := dataprovider.NewReleasesDataProvider(githubVersion, tasVersion, ciProviders, bumpPullRequests, cfg)
+	imagesDataProvider := dataprovider.NewImagesDataProvider(ciProviders, cfg)
+	pluginsDataProvider := dataprovider.NewPluginsDataProvider(ctx, githubClient, ciProviders, persistentCache, cfg)
+	buildpacksDataProvider := dataprovider.NewBuildpacksDataProvider(ctx, githubClient, ciProviders, persistentCache, cfg)
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		data := baseDataProvider.Get()
-		baseTmpl.Execute(w, data)
+	releasesTable := func(provider interface {
+		Get(targetGoVersion string) dataprovider.ReleasesData
+	}) tableHandler {
+		return func(w http.ResponseWriter, r *http.Request, targetGoVersion string, rows []artifact.Row) {
+			data := releasesView{
+				ReleasesData: provider.Get(targetGoVersion),
+				ShowTiles:    auth.FromRequest(r).Authorized,
+				Bumped:       rowBumped(rows),
+				Deadlines:    rowDeadlines(rows),
+			}
+			render(w, releasesTableTmpl, data)
+		}
+	}
+	registry := artifact.NewRegistry()
+	tables := map[string]tableHandler{}
+	registry.Register(releasesDataProvider)
+	tables[releasesDataProvider.Kind()] = releasesTable(releasesDataProvider)
+	registry.Register(imagesDataProvider)
+	tables[imagesDataProvider.Kind()] = func(w http.ResponseWriter, r *http.Request, targetGoVersion string, rows []artifact.Row) {
+		render(w, imagesTableTmpl, imagesView{ImagesData: imagesDataProvider.Get(targetGoVersion), Deadlines: rowDeadlines(rows)})
+	}
+	registry.Register(pluginsDataProvider)
+	tables[pluginsDataProvider.Kind()] = func(w http.ResponseWriter, r *http.Request, targetGoVersion string, rows []artifact.Row) {
+		render(w, pluginsTableTmpl, pluginsView{PluginsData: pluginsDataProvider.Get(targetGoVersion), Deadlines: rowDeadlines(rows)})
+	}
+	registry.Register(buildpacksDataProvider)
+	tables[buildpacksDataProvider.Kind()] = func(w http.ResponseWriter, r *http.Request, targetGoVersion string, rows []artifact.Row) {
+		render(w, buildpacksTableTmpl, buildpacksView{BuildpacksData: buildpacksDataProvider.Get(targetGoVersion), Deadlines: rowDeadlines(rows)})
+	}
+	for _, moduleTarget := range cfg.ModuleTargets {
+		moduleVersion := version.NewGoModuleVersion(ctx, githubClient, persistentCache, moduleTarget.Module)
+		moduleDataProvider := dataprovider.NewModuleDataProvider(moduleTarget, moduleVersion, tasVersion, ciProviders, cfg)
+		registry.Register(moduleDataProvider)
+		tables[moduleDataProvider.Kind()] = releasesTable(moduleDataProvider)
+	}
+	for _, externalProvider := range cfg.ExternalProviders {
+		registry.Register(external.NewProvider(ctx, externalProvider, func() string {
+			return baseDataProvider.Get().TargetGoVersion
+		}))
+	}
+
+	if cfg.TrackingIssue != nil {
+		issueTracker := tracking.NewIssueTracker(ctx, githubClient, *cfg.TrackingIssue)
+		go func() {
+			for {
+				targetGoVersion := baseDataProvider.Get().TargetGoVersion
+				err := issueTracker.Sync(targetGoVersion, registry.Providers())
+				if err != nil {
+					log.Printf("failed to sync tracking issue: %s", err.Error())
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(tracking.SYNC_INTERVAL):
+				}
+			}
+		}()
+	}
+
+	// the feed and the metrics are both updated from here, so that neither a
+	// feed request nor a scrape refreshes the providers
+	feedRecorder := feed.NewRecorder(persistentCache)
+	metricsUpdater := dataprovider.NewMetricsUpdater(goCalendar)
+	go func() {
+		for {
+			targetGoVersion := baseDataProvider.Get().TargetGoVersion
+			rows := registry.Rows(targetGoVersion, "")
+			feedRecorder.Observe(targetGoVersion, rows, time.Now())
+			metricsUpdater.Update(targetGoVersion, rows, time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(feed.OBSERVE_INTERVAL):
+			}
+		}
+	}()
+
+	authenticator, err := auth.New(ctx, cfg.Auth)
+	if err != nil {
+		log.Fatalf("failed to set up auth: %s", err.Error())
+	}
+
+	healthChecks := health.NewHealth()
+	healthChecks.AddCheck("fingerprint-cache", func() error {
+		if !boshPackageVersion.WarmedUp() {
+			return errors.New("golang fingerprint cache is warming up")
+		}
+		return nil
+	})
+	healthChecks.AddCheck("target-golang-version", func() error {
+		if baseDataProvider.Get().TargetGoVersion == "" {
+			return errors.New("last refresh of the target golang version failed")
+		}
+		return nil
+	})
+	healthChecks.SetDetails(func() interface{} {
+		return dataprovider.RefreshStates()
 	})
 
-	http.HandleFunc("/releases_table", func(w http.ResponseWriter, r *http.Request) {
-		targetGoVersion := r.URL.Query().Get("target")
-		data := releasesDataProvider.Get(targetGoVersion)
-		releasesTableTmpl.Execute(w, data)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		var sections []section
+		for _, provider := range registry.Providers() {
+			sections = append(sections, section{Kind: provider.Kind(), Title: provider.Title()})
+		}
+		baseData := baseDataProvider.Get()
+		var campaign *config.Campaign
+		if targetCampaign, ok := cfg.CampaignFor(baseData.TargetGoVersion); ok {
+			campaign = &targetCampaign
+		}
+		data := baseView{
+			BaseData: baseData,
+			Sections: sections,
+			Campaign: campaign,
+			User:     auth.FromRequest(r).Subject,
+			LoginURL: authenticator.LoginURL(),
+		}
+		render(w, baseTmpl, data)
 	})
 
-	http.HandleFunc("/images_table", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/table", func(w http.ResponseWriter, r *http.Request) {
+		kind := r.URL.Query().Get("kind")
 		targetGoVersion := r.URL.Query().Get("target")
-		data := imagesDataProvider.Get(targetGoVersion)
-		imagesTableTmpl.Execute(w, data)
+		provider, ok := registry.Get(kind)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		rows := campaignRows(cfg, targetGoVersion, visibleRows(r, provider.Rows(targetGoVersion)))
+		if table, ok := tables[kind]; ok {
+			table(w, r, targetGoVersion, rows)
+			return
+		}
+		data := artifactsView{
+			Title: provider.Title(),
+			Rows:  rows,
+		}
+		render(w, artifactsTableTmpl, data)
+	})
+
+	supportMatrixProvider := dataprovider.NewSupportMatrixProvider(baseDataProvider, registry, goCalendar)
+	mux.HandleFunc("/support_matrix", func(w http.ResponseWriter, r *http.Request) {
+		render(w, supportMatrixTmpl, supportMatrixProvider.Get(auth.FromRequest(r).Authorized))
 	})
 
-	http.HandleFunc("/plugins_table", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/v1/artifacts", func(w http.ResponseWriter, r *http.Request) {
 		targetGoVersion := r.URL.Query().Get("target")
-		data := pluginsDataProvider.Get(targetGoVersion)
-		pluginsTableTmpl.Execute(w, data)
+		if targetGoVersion == "" {
+			targetGoVersion = baseDataProvider.Get().TargetGoVersion
+		}
+		report := artifact.Report{
+			Target: targetGoVersion,
+			Rows:   campaignRows(cfg, targetGoVersion, visibleRows(r, registry.Rows(targetGoVersion, r.URL.Query().Get("kind")))),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(report)
+		if err != nil {
+			log.Printf("failed to write artifacts report: %s", err.Error())
+		}
 	})
 
-	http.Handle("/images/", http.StripPrefix("/images/", http.FileServer(http.Dir("./images"))))
+	mux.Handle(feed.FEED_PATH, feed.NewHandler(feedRecorder))
 
-	err = http.ListenAndServe(":8080", nil)
-	if err != nil {
+	mux.Handle(badge.PATH_PREFIX, badge.NewHandler(registry, badgeTmpl, func() string {
+		return baseDataProvider.Get().TargetGoVersion
+	}))
+
+	if webhookSecret := os.Getenv("GITHUB_WEBHOOK_SECRET"); webhookSecret != "" {
+		refresh := func() {
+			targetGoVersion := baseDataProvider.Get().TargetGoVersion
+			for _, provider := range registry.Providers() {
+				provider.Rows(targetGoVersion)
+			}
+		}
+		mux.Handle("/webhooks/github", webhook.NewGithubReceiver(webhookSecret, registry.Providers(), boshPackageVersion, refresh))
+	}
+
+	mux.Handle("/metrics", auth.RequireAuthorized(metrics.Default))
+	mux.HandleFunc("/healthz", healthChecks.Liveness)
+	mux.HandleFunc("/readyz", healthChecks.Readiness)
+
+	mux.Handle("/images/", http.StripPrefix("/images/", http.FileServer(http.Dir("./images"))))
+	authenticator.RegisterHandlers(mux)
+
+	server := &http.Server{
+		Addr:         *listenAddr,
+		Handler:      authenticator.Middleware(mux),
+		ReadTimeout:  READ_TIMEOUT,
+		WriteTimeout: WRITE_TIMEOUT,
+		IdleTimeout:  IDLE_TIMEOUT,
+		BaseContext: func(_ net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", *listenAddr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serverErr:
 		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Fatalf("failed to shut down: %s", err.Error())
+	}
+	err = persistentCache.Flush()
+	if err != nil {
+		log.Fatalf("failed to write cache: %s", err.Error())
+	}
+}
+
+// parseTableTemplate parses a table template together with the partials
+// shared by all tables.
+func parseTableTemplate(path string) *template.Template {
+	return template.Must(template.ParseFiles(path, "templates/ci_cell.html", "templates/cell_error.html", "templates/deadline.html", "templates/warnings.html"))
+}
+
+// goCalendarPath returns the locally supplied Go release calendar, if any, or
+// the bundled one.
+func goCalendarPath() string {
+	path := os.Getenv("GO_RELEASE_CALENDAR")
+	if path == "" {
+		path = DEFAULT_GO_CALENDAR
+	}
+	return path
+}
+
+func defaultListenAddr() string {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = DEFAULT_PORT
+	}
+	return ":" + port
+}
+
+// warmUp retries populating the fingerprint cache until it succeeds, so a
+// transient GitHub failure does not keep the app from starting.
+func warmUp(ctx context.Context, boshPackageVersion interface{ PopulateCache() error }) {
+	for {
+		err := boshPackageVersion.PopulateCache()
+		if err == nil {
+			return
+		}
+		log.Printf("failed to warm up cache: %s", err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(WARMUP_RETRY_DELAY):
+		}
+	}
+}
+
+// visibleRows hides tile stages from callers that are not authorized to see
+// them.
+func visibleRows(r *http.Request, rows []artifact.Row) []artifact.Row {
+	if auth.FromRequest(r).Authorized {
+		return rows
+	}
+	visible := make([]artifact.Row, 0, len(rows))
+	for _, row := range rows {
+		visible = append(visible, row.WithoutTiles())
+	}
+	return visible
+}
+
+// campaignRows adds the deadlines of the campaign for the target, if any, to
+// the rows. Tile stages must already be removed for unauthorized callers so
+// that their deadlines are not reported either.
+func campaignRows(cfg config.Config, targetGoVersion string, rows []artifact.Row) []artifact.Row {
+	campaign, ok := cfg.CampaignFor(targetGoVersion)
+	if !ok {
+		return rows
+	}
+	return artifact.ApplyDeadlines(rows, campaign.DeadlineDates, time.Now())
+}
+
+func rowBumped(rows []artifact.Row) map[string]bool {
+	bumped := map[string]bool{}
+	for _, row := range rows {
+		bumped[row.Name] = row.AllBumped
+	}
+	return bumped
+}
+
+func rowDeadlines(rows []artifact.Row) map[string]*artifact.Deadline {
+	deadlines := map[string]*artifact.Deadline{}
+	for _, row := range rows {
+		if row.Deadline != nil {
+			deadlines[row.Name] = row.Deadline
+		}
+	}
+	return deadlines
+}
+
+// render executes the template into a buffer so that a failing template
+// results in an error response instead of a truncated page.
+func render(w http.ResponseWriter, tmpl *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		log.Printf("failed to render %s: %s", tmpl.Name(), err.Error())
+		http.Error(w, "failed to render page", http.StatusInternalServerError)
+		return
 	}
+	buf.WriteTo(w)
 }
@@ -0,0 +1,22 @@
+package metrics
+
+var (
+	Default = NewRegistry()
+
+	BumpedOnDev = Default.NewGauge("golang_bump_bumped_on_dev", "Whether the develop branch uses the target golang version.", "kind", "name")
+	Released    = Default.NewGauge("golang_bump_released", "Whether the latest release uses the target golang version.", "kind", "name")
+	Shipped     = Default.NewGauge("golang_bump_shipped", "Whether a tile ships a release with the target golang version.", "kind", "name", "tile")
+	AllBumped   = Default.NewGauge("golang_bump_all_bumped", "Whether every stage uses the target golang version.", "kind", "name")
+	LagDays     = Default.NewGauge("golang_bump_lag_days", "Days since the target golang minor was released while the item is not fully bumped.", "kind", "name")
+
+	RefreshDuration = Default.NewGauge("golang_bump_refresh_duration_seconds", "Duration of the last data provider refresh.", "provider")
+	RefreshTotal    = Default.NewCounter("golang_bump_refreshes_total", "Data provider refreshes.", "provider")
+	RefreshFailures = Default.NewCounter("golang_bump_refresh_failures_total", "Failures while refreshing data providers.", "provider")
+
+	Requests           = Default.NewCounter("golang_bump_requests_total", "Outgoing API requests.", "service")
+	RequestErrors      = Default.NewCounter("golang_bump_request_errors_total", "Outgoing API requests that failed or returned an error status.", "service")
+	RateLimitRemaining = Default.NewGauge("golang_bump_rate_limit_remaining", "Remaining API rate limit reported by the service.", "service")
+
+	FingerprintCacheSize   = Default.NewGauge("golang_bump_fingerprint_cache_size", "Golang package fingerprints in the cache.")
+	FingerprintCacheMisses = Default.NewCounter("golang_bump_fingerprint_cache_misses_total", "Golang package fingerprint lookups that missed the cache.")
+)
@@ -0,0 +1 @@
+package metrics // import "github.com/cloudfoundry-incubator/golang-bump-progress/metrics"
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TYPE_COUNTER = "counter"
+	TYPE_GAUGE   = "gauge"
+)
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// Vec is a metric family with a fixed set of label names.
+type Vec struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	samples    map[string]*sample
+	mux        sync.Mutex
+}
+
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	v.sample(labelValues).value += delta
+}
+
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+func (v *Vec) Set(value float64, labelValues ...string) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	v.sample(labelValues).value = value
+}
+
+// Reset drops all samples, so that rows removed from the data stop being
+// reported.
+func (v *Vec) Reset() {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	v.samples = map[string]*sample{}
+}
+
+func (v *Vec) sample(labelValues []string) *sample {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{labelValues: append([]string{}, labelValues...)}
+		v.samples[key] = s
+	}
+	return s
+}
+
+func (v *Vec) write(w io.Writer) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.metricType)
+
+	keys := make([]string, 0, len(v.samples))
+	for key := range v.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := v.samples[key]
+		fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labelNames, s.labelValues), strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+}
+
+func formatLabels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%q", name, values[i])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Registry renders registered metrics in the Prometheus text format.
+// Collectors run before every scrape to refresh derived gauges.
+type Registry struct {
+	vecs       []*Vec
+	collectors []func()
+	mux        sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) NewCounter(name string, help string, labelNames ...string) *Vec {
+	return r.register(name, help, TYPE_COUNTER, labelNames)
+}
+
+func (r *Registry) NewGauge(name string, help string, labelNames ...string) *Vec {
+	return r.register(name, help, TYPE_GAUGE, labelNames)
+}
+
+func (r *Registry) OnCollect(collector func()) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.collectors = append(r.collectors, collector)
+}
+
+func (r *Registry) register(name string, help string, metricType string, labelNames []string) *Vec {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	vec := &Vec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		samples:    map[string]*sample{},
+	}
+	r.vecs = append(r.vecs, vec)
+	return vec
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.Lock()
+	collectors := append([]func(){}, r.collectors...)
+	vecs := append([]*Vec{}, r.vecs...)
+	r.mux.Unlock()
+
+	for _, collector := range collectors {
+		collector()
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, vec := range vecs {
+		vec.write(w)
+	}
+}
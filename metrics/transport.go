@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	RATE_LIMIT_HEADER = "X-RateLimit-Remaining"
+)
+
+type transport struct {
+	service string
+	base    http.RoundTripper
+}
+
+// NewTransport counts requests and errors for a service and records the
+// remaining rate limit from response headers.
+func NewTransport(service string, base http.RoundTripper) *transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{
+		service: service,
+		base:    base,
+	}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	Requests.Inc(t.service)
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		RequestErrors.Inc(t.service)
+		return res, err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		RequestErrors.Inc(t.service)
+	}
+	if remaining, err := strconv.ParseFloat(res.Header.Get(RATE_LIMIT_HEADER), 64); err == nil {
+		RateLimitRemaining.Set(remaining, t.service)
+	}
+	return res, nil
+}
@@ -0,0 +1,149 @@
+package tracking
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/dataprovider"
+	"github.com/google/go-github/v54/github"
+)
+
+const (
+	SYNC_INTERVAL          = 10 * time.Minute
+	DEFAULT_TRACKING_LABEL = "golang-bump"
+	ISSUE_STATE_OPEN       = "open"
+	ISSUE_STATE_CLOSED     = "closed"
+)
+
+type issueTracker struct {
+	githubClient *github.Client
+	config       config.TrackingIssue
+	ctx          context.Context
+}
+
+func NewIssueTracker(ctx context.Context, githubClient *github.Client, cfg config.TrackingIssue) *issueTracker {
+	if cfg.Label == "" {
+		cfg.Label = DEFAULT_TRACKING_LABEL
+	}
+	return &issueTracker{
+		githubClient: githubClient,
+		config:       cfg,
+		ctx:          ctx,
+	}
+}
+
+// Sync creates or updates the tracking issue for the target golang version so
+// that it matches the provided data. The issue is closed once every item is
+// bumped. Nothing is written when the issue is already up to date or when the
+// tracker runs in dry-run mode.
+func (t *issueTracker) Sync(targetGoVersion string, providers []artifact.Provider) error {
+	if targetGoVersion == "" {
+		return fmt.Errorf("no target golang version to track")
+	}
+	title := IssueTitle(targetGoVersion)
+	body, done := IssueBody(targetGoVersion, providers)
+	state := ISSUE_STATE_OPEN
+	if done {
+		state = ISSUE_STATE_CLOSED
+	}
+
+	issue, err := t.findIssue(title)
+	if err != nil {
+		return err
+	}
+
+	if issue == nil {
+		if done {
+			return nil
+		}
+		if t.config.DryRun {
+			log.Printf("dry run: would create tracking issue %q in %s/%s", title, t.config.Owner, t.config.Repo)
+			return nil
+		}
+		labels := []string{t.config.Label}
+		_, _, err = t.githubClient.Issues.Create(t.ctx, t.config.Owner, t.config.Repo, &github.IssueRequest{
+			Title:  &title,
+			Body:   &body,
+			Labels: &labels,
+		})
+		return err
+	}
+
+	if issue.GetBody() == body && issue.GetState() == state {
+		return nil
+	}
+	if t.config.DryRun {
+		log.Printf("dry run: would update tracking issue #%d in %s/%s (state: %s)", issue.GetNumber(), t.config.Owner, t.config.Repo, state)
+		return nil
+	}
+	_, _, err = t.githubClient.Issues.Edit(t.ctx, t.config.Owner, t.config.Repo, issue.GetNumber(), &github.IssueRequest{
+		Body:  &body,
+		State: &state,
+	})
+	return err
+}
+
+func (t *issueTracker) findIssue(title string) (*github.Issue, error) {
+	opts := &github.IssueListByRepoOptions{
+		State:       "all",
+		Labels:      []string{t.config.Label},
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		issues, response, err := t.githubClient.Issues.ListByRepo(t.ctx, t.config.Owner, t.config.Repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			if !issue.IsPullRequest() && issue.GetTitle() == title {
+				return issue, nil
+			}
+		}
+		if response.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+func IssueTitle(targetGoVersion string) string {
+	return fmt.Sprintf("Golang %s bump", targetGoVersion)
+}
+
+// IssueBody renders the tracking checklist with a section per provider and
+// reports whether every item in it is checked. Module rows track module
+// versions rather than golang and are left out. A checklist without items is
+// never done, so that missing data does not close the issue.
+func IssueBody(targetGoVersion string, providers []artifact.Provider) (string, bool) {
+	var b strings.Builder
+	done := true
+	items := 0
+
+	fmt.Fprintf(&b, "Tracking the golang %s bump. This issue is updated automatically.\n", targetGoVersion)
+	for _, provider := range providers {
+		if strings.HasPrefix(provider.Kind(), dataprovider.KIND_MODULE+"/") {
+			continue
+		}
+		rows := provider.Rows(targetGoVersion)
+		if len(rows) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", provider.Title())
+		for _, row := range rows {
+			mark := " "
+			if row.AllBumped {
+				mark = "x"
+			} else {
+				done = false
+			}
+			fmt.Fprintf(&b, "- [%s] [%s](%s)\n", mark, row.Name, row.URL)
+			items++
+		}
+	}
+	return b.String(), done && items > 0
+}
@@ -0,0 +1 @@
+package tracking // import "github.com/cloudfoundry-incubator/golang-bump-progress/tracking"
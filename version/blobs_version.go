@@ -0,0 +1,69 @@
+package version
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/google/go-github/v54/github"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	BLOBS_FILE = "config/blobs.yml"
+)
+
+// golangBlobRegexp matches golang archives such as go1.21.5.linux-amd64.tar.gz,
+// go1.21.5.windows-amd64.zip, go1.21.5.src.tar.gz and golang-1.21.5.tar.gz.
+var golangBlobRegexp = regexp.MustCompile(`^go(?:lang)?-?(\d+\.\d+(?:\.\d+)?(?:(?:rc|beta)\d+)?)(?:\.src|\.([a-z0-9]+)-[a-z0-9]+)?\.(?:tar\.gz|zip)$`)
+
+// getBlobGolangVersion reads the golang version of releases that add a golang
+// archive as a blob and package it themselves instead of vendoring a golang
+// package. Archives built for another platform are skipped. When several
+// versions are present the lowest is returned, since that one is still to be
+// bumped.
+func (f *githubVersion) getBlobGolangVersion(release config.Release, ref string) (golangVersionResult, error) {
+	blobsContent, _, response, err := f.githubClient.Repositories.GetContents(f.ctx, release.Owner, release.Repo, BLOBS_FILE, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return golangVersionResult{}, NotFoundError{fmt.Errorf("golang package not found for release: %s", release.Name)}
+		}
+		return golangVersionResult{}, err
+	}
+	content, err := blobsContent.GetContent()
+	if err != nil {
+		return golangVersionResult{}, err
+	}
+	var blobs map[string]interface{}
+	err = yaml.Unmarshal([]byte(content), &blobs)
+	if err != nil {
+		return golangVersionResult{}, fmt.Errorf("failed to parse %s: %w", BLOBS_FILE, err)
+	}
+
+	var lowest *semver.Version
+	var lowestVersion string
+	for blobPath := range blobs {
+		matches := golangBlobRegexp.FindStringSubmatch(path.Base(blobPath))
+		if matches == nil {
+			continue
+		}
+		if matches[2] != "" && release.Platform != "" && matches[2] != release.Platform {
+			continue
+		}
+		golangV, err := parseGoVersion(matches[1])
+		if err != nil {
+			return golangVersionResult{}, fmt.Errorf("failed to parse golang version of blob %s: %w", blobPath, err)
+		}
+		if lowest == nil || golangV.LessThan(lowest) {
+			lowest = golangV
+			lowestVersion = matches[1]
+		}
+	}
+	if lowest == nil {
+		return golangVersionResult{}, NotFoundError{fmt.Errorf("golang package or blob not found for release: %s", release.Name)}
+	}
+	return golangVersionResult{Version: lowestVersion, FromBlobs: true}, nil
+}
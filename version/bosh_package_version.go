@@ -6,7 +6,9 @@ import (
 	"log"
 	"regexp"
 	"sync"
+	"sync/atomic"
 
+	"github.com/cloudfoundry-incubator/golang-bump-progress/metrics"
 	"github.com/google/go-github/v54/github"
 )
 
@@ -32,6 +34,7 @@ type boshPackageVersion struct {
 	githubClient         *github.Client
 	fingerprintsCache    map[string]string
 	fingerprintsCacheMux sync.Mutex
+	warmedUp             atomic.Bool
 	ctx                  context.Context
 }
 
@@ -47,11 +50,6 @@ func NewBoshPackageVersion(ctx context.Context, githubClient *github.Client) *bo
 func (v *boshPackageVersion) PopulateCache() error {
 	v.fingerprintsCacheMux.Lock()
 	defer v.fingerprintsCacheMux.Unlock()
-	linuxVersionFile := fmt.Sprintf("packages/%s/version", GOLANG_LINUX_PACKAGE)
-	windowsVersionFile := fmt.Sprintf("packages/%s/version", GOLANG_WINDOWS_PACKAGE)
-	linuxFingerprintFile := fmt.Sprintf(`.final_builds/packages/%s/index.yml`, GOLANG_LINUX_PACKAGE)
-	windowsFingerprintFile := fmt.Sprintf(`.final_builds/packages/%s/index.yml`, GOLANG_WINDOWS_PACKAGE)
-
 	log.Println("Populating cache...")
 
 	commitResults, _, err := v.githubClient.Repositories.ListCommits(
@@ -67,44 +65,78 @@ func (v *boshPackageVersion) PopulateCache() error {
 	}
 
 	for _, commitResult := range commitResults {
-		commitSHA := commitResult.GetSHA()
-		commit, _, err := v.githubClient.Repositories.GetCommit(
-			v.ctx,
-			GOLANG_BOSH_RELEASE_OWNER,
-			GOLANG_BOSH_RELEASE_REPO,
-			commitSHA,
-			&github.ListOptions{PerPage: FILES_IN_FINAL_RELEASE},
-		)
+		err = v.cacheCommit(commitResult.GetSHA())
 		if err != nil {
 			return err
 		}
+	}
+	metrics.FingerprintCacheSize.Set(float64(len(v.fingerprintsCache)))
+	v.warmedUp.Store(true)
+	log.Println("Populated cache...")
+	return nil
+}
 
-		if len(commit.Files) < 1 {
-			return fmt.Errorf("failed to get files for %s", commitSHA)
+// UpdateFromCommits adds the fingerprints of golang packages finalized in
+// the given commits, for example the commits of a push webhook, to the cache.
+func (v *boshPackageVersion) UpdateFromCommits(commitSHAs []string) error {
+	v.fingerprintsCacheMux.Lock()
+	defer v.fingerprintsCacheMux.Unlock()
+	for _, commitSHA := range commitSHAs {
+		err := v.cacheCommit(commitSHA)
+		if err != nil {
+			return err
 		}
+	}
+	metrics.FingerprintCacheSize.Set(float64(len(v.fingerprintsCache)))
+	return nil
+}
 
-		for _, file := range commit.Files {
-			switch file.GetFilename() {
-			case linuxFingerprintFile:
-				fingerprint, version, err := v.getFingerprintVersionFromPatch(file.GetPatch(), linuxVersionFile, commitSHA)
-				if err != nil {
-					return err
-				}
-				v.fingerprintsCache[fingerprint] = version
-			case windowsFingerprintFile:
-				fingerprint, version, err := v.getFingerprintVersionFromPatch(file.GetPatch(), windowsVersionFile, commitSHA)
-				if err != nil {
-					return err
-				}
-				v.fingerprintsCache[fingerprint] = version
-			}
+// cacheCommit caches the fingerprints of the golang packages finalized in a
+// commit. The cache lock must be held.
+func (v *boshPackageVersion) cacheCommit(commitSHA string) error {
+	linuxVersionFile := fmt.Sprintf("packages/%s/version", GOLANG_LINUX_PACKAGE)
+	windowsVersionFile := fmt.Sprintf("packages/%s/version", GOLANG_WINDOWS_PACKAGE)
+	linuxFingerprintFile := fmt.Sprintf(`.final_builds/packages/%s/index.yml`, GOLANG_LINUX_PACKAGE)
+	windowsFingerprintFile := fmt.Sprintf(`.final_builds/packages/%s/index.yml`, GOLANG_WINDOWS_PACKAGE)
+
+	commit, _, err := v.githubClient.Repositories.GetCommit(
+		v.ctx,
+		GOLANG_BOSH_RELEASE_OWNER,
+		GOLANG_BOSH_RELEASE_REPO,
+		commitSHA,
+		&github.ListOptions{PerPage: FILES_IN_FINAL_RELEASE},
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(commit.Files) < 1 {
+		return fmt.Errorf("failed to get files for %s", commitSHA)
+	}
 
+	for _, file := range commit.Files {
+		switch file.GetFilename() {
+		case linuxFingerprintFile:
+			fingerprint, version, err := v.getFingerprintVersionFromPatch(file.GetPatch(), linuxVersionFile, commitSHA)
+			if err != nil {
+				return err
+			}
+			v.fingerprintsCache[fingerprint] = version
+		case windowsFingerprintFile:
+			fingerprint, version, err := v.getFingerprintVersionFromPatch(file.GetPatch(), windowsVersionFile, commitSHA)
+			if err != nil {
+				return err
+			}
+			v.fingerprintsCache[fingerprint] = version
 		}
 	}
-	log.Println("Populated cache...")
 	return nil
 }
 
+func (v *boshPackageVersion) WarmedUp() bool {
+	return v.warmedUp.Load()
+}
+
 func (v *boshPackageVersion) GetFingerprintVersion(fingerprint string, golangPackage string) (string, error) {
 	v.fingerprintsCacheMux.Lock()
 	defer v.fingerprintsCacheMux.Unlock()
@@ -112,6 +144,7 @@ func (v *boshPackageVersion) GetFingerprintVersion(fingerprint string, golangPac
 		return version, nil
 	}
 	log.Printf("could not find fingerprint in cache: %s\n", fingerprint)
+	metrics.FingerprintCacheMisses.Inc()
 
 	versionFile := fmt.Sprintf("packages/%s/version", golangPackage)
 	fingerprintFile := fmt.Sprintf(`.final_builds/packages/%s/index.yml`, golangPackage)
@@ -153,6 +186,7 @@ func (v *boshPackageVersion) GetFingerprintVersion(fingerprint string, golangPac
 						return "", err
 					}
 					v.fingerprintsCache[fingerprint] = version
+					metrics.FingerprintCacheSize.Set(float64(len(v.fingerprintsCache)))
 					return version, nil
 				}
 			}
@@ -0,0 +1,191 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/google/go-github/v54/github"
+)
+
+const (
+	OPEN_PULL_REQUESTS         = 50
+	FILES_IN_PULL_REQUEST      = 100
+	DEFAULT_BUMP_TITLE_PATTERN = `(?i)golang|\bgo\s?1\.\d+`
+
+	REVIEW_APPROVED          = "approved"
+	REVIEW_CHANGES_REQUESTED = "changes requested"
+	REVIEW_REQUIRED          = "review required"
+
+	CHECKS_PASSING = "passing"
+	CHECKS_FAILING = "failing"
+	CHECKS_PENDING = "pending"
+	CHECKS_NONE    = "none"
+)
+
+var (
+	GOLANG_SPEC_LOCK_RE   = regexp.MustCompile(`^packages/golang-[^/]+/spec\.lock$`)
+	GO_MOD_RE             = regexp.MustCompile(`(^|/)go\.mod$`)
+	DEFAULT_BUMP_TITLE_RE = regexp.MustCompile(DEFAULT_BUMP_TITLE_PATTERN)
+)
+
+type PullRequestInfo struct {
+	Number       int
+	Title        string
+	URL          string
+	Draft        bool
+	ReviewStatus string
+	CheckStatus  string
+}
+
+type bumpPullRequests struct {
+	githubClient        *github.Client
+	defaultTitlePattern *regexp.Regexp
+	ctx                 context.Context
+}
+
+// NewBumpPullRequests uses the title pattern compiled by config.LoadConfig,
+// or the default pattern when none is configured.
+func NewBumpPullRequests(ctx context.Context, githubClient *github.Client, titleRegexp *regexp.Regexp) *bumpPullRequests {
+	if titleRegexp == nil {
+		titleRegexp = DEFAULT_BUMP_TITLE_RE
+	}
+	return &bumpPullRequests{
+		githubClient:        githubClient,
+		defaultTitlePattern: titleRegexp,
+		ctx:                 ctx,
+	}
+}
+
+// GetOpenBumpPullRequests returns open pull requests that either have a
+// title matching the bump pattern or change a vendored golang package or a
+// go.mod file. Pull requests that fail to be checked are left out and their
+// errors returned along with the others.
+func (b *bumpPullRequests) GetOpenBumpPullRequests(release config.Release) ([]PullRequestInfo, error) {
+	titleRE := b.defaultTitlePattern
+	if release.BumpPRTitleRegexp != nil {
+		titleRE = release.BumpPRTitleRegexp
+	}
+
+	pullRequests, _, err := b.githubClient.PullRequests.List(b.ctx, release.Owner, release.Repo, &github.PullRequestListOptions{
+		State:       "open",
+		Base:        release.DevelopBranch,
+		ListOptions: github.ListOptions{PerPage: OPEN_PULL_REQUESTS},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []PullRequestInfo
+	var errs []error
+	for _, pr := range pullRequests {
+		isBump := titleRE.MatchString(pr.GetTitle())
+		if !isBump {
+			isBump, err = b.changesGolang(release, pr.GetNumber())
+			if err != nil {
+				errs = append(errs, fmt.Errorf("pull request #%d: %w", pr.GetNumber(), err))
+				continue
+			}
+		}
+		if !isBump {
+			continue
+		}
+
+		reviewStatus, err := b.reviewStatus(release, pr.GetNumber())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pull request #%d: %w", pr.GetNumber(), err))
+			continue
+		}
+		checkStatus, err := b.checkStatus(release, pr.GetHead().GetSHA())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pull request #%d: %w", pr.GetNumber(), err))
+			continue
+		}
+		result = append(result, PullRequestInfo{
+			Number:       pr.GetNumber(),
+			Title:        pr.GetTitle(),
+			URL:          pr.GetHTMLURL(),
+			Draft:        pr.GetDraft(),
+			ReviewStatus: reviewStatus,
+			CheckStatus:  checkStatus,
+		})
+	}
+	return result, errors.Join(errs...)
+}
+
+func (b *bumpPullRequests) changesGolang(release config.Release, number int) (bool, error) {
+	files, _, err := b.githubClient.PullRequests.ListFiles(b.ctx, release.Owner, release.Repo, number, &github.ListOptions{PerPage: FILES_IN_PULL_REQUEST})
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		if GOLANG_SPEC_LOCK_RE.MatchString(file.GetFilename()) || GO_MOD_RE.MatchString(file.GetFilename()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (b *bumpPullRequests) reviewStatus(release config.Release, number int) (string, error) {
+	reviews, _, err := b.githubClient.PullRequests.ListReviews(b.ctx, release.Owner, release.Repo, number, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return "", err
+	}
+
+	latestByUser := map[string]string{}
+	for _, review := range reviews {
+		switch review.GetState() {
+		case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+			latestByUser[review.GetUser().GetLogin()] = review.GetState()
+		}
+	}
+
+	status := REVIEW_REQUIRED
+	for _, state := range latestByUser {
+		switch state {
+		case "CHANGES_REQUESTED":
+			return REVIEW_CHANGES_REQUESTED, nil
+		case "APPROVED":
+			status = REVIEW_APPROVED
+		}
+	}
+	return status, nil
+}
+
+func (b *bumpPullRequests) checkStatus(release config.Release, sha string) (string, error) {
+	checkRuns, _, err := b.githubClient.Checks.ListCheckRunsForRef(b.ctx, release.Owner, release.Repo, sha, &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}})
+	if err != nil {
+		return "", err
+	}
+	combinedStatus, _, err := b.githubClient.Repositories.GetCombinedStatus(b.ctx, release.Owner, release.Repo, sha, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if len(checkRuns.CheckRuns) == 0 && combinedStatus.GetTotalCount() == 0 {
+		return CHECKS_NONE, nil
+	}
+
+	status := CHECKS_PASSING
+	for _, run := range checkRuns.CheckRuns {
+		if run.GetStatus() != "completed" {
+			status = CHECKS_PENDING
+			continue
+		}
+		switch run.GetConclusion() {
+		case "failure", "timed_out", "cancelled", "action_required":
+			return CHECKS_FAILING, nil
+		}
+	}
+	if combinedStatus.GetTotalCount() > 0 {
+		switch combinedStatus.GetState() {
+		case "failure", "error":
+			return CHECKS_FAILING, nil
+		case "pending":
+			status = CHECKS_PENDING
+		}
+	}
+	return status, nil
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
 	"github.com/google/go-github/v54/github"
 	"gopkg.in/yaml.v2"
@@ -16,114 +18,375 @@ type NotFoundError struct {
 	err error
 }
 
+func NewNotFoundError(err error) NotFoundError {
+	return NotFoundError{err}
+}
+
 func (e NotFoundError) Error() string {
 	return e.err.Error()
 }
 
 type VersionInfo struct {
-	GolangVersion  string
-	ReleaseVersion string
+	GolangVersion       string
+	ReleaseVersion      string
+	Tag                 string
+	PublishedAt         time.Time
+	PatchGolangVersion  string
+	PatchReleaseVersion string
+	PatchTag            string
+	PatchPublishedAt    time.Time
+}
+
+// DevelopVersionInfo and ReleasedVersionInfo set FromBlobs when the golang
+// version was read from a blob instead of a vendored golang package.
+type DevelopVersionInfo struct {
+	GolangVersion string
+	FromBlobs     bool
+}
+
+type ReleasedVersionInfo struct {
+	GolangVersion string
+	FromBlobs     bool
+	Tag           string
+	Reason        string
+	stable        []SelectedRelease
 }
 
+// golangVersionResult is what refVersion read from one ref.
+type golangVersionResult struct {
+	Version   string
+	FromBlobs bool
+}
+
+// tagGolangVersion is the cached golang version on a tag.
+type tagGolangVersion struct {
+	GolangVersion string
+	NotFound      bool
+	FromBlobs     bool
+}
+
+// githubVersion finds the version of what is being tracked, by default the
+// packaged golang, on the refs of a release. refVersion reads it from one ref.
+// prefetchTags, when set, is given the tags a release history search is
+// about to read, searchFanout at a time.
 type githubVersion struct {
-	githubClient          *github.Client
-	boshPackageVersion    *boshPackageVersion
-	firstReleasedVersions map[string]VersionInfo
-	ctx                   context.Context
+	githubClient       *github.Client
+	boshPackageVersion *boshPackageVersion
+	cache              *persistentCache
+	ctx                context.Context
+	subject            string
+	cachePrefix        string
+	refVersion         func(release config.Release, ref string) (golangVersionResult, error)
+	prefetchTags       func(release config.Release, tags []string)
+	searchFanout       int
+	releaseLists       *memo[[]*github.RepositoryRelease]
+	releaseHistories   *releaseHistories
+	packageListings    *memo[[]*github.RepositoryContent]
+	goModListings      *memo[[]*github.TreeEntry]
 }
 
-func NewGithubVersion(ctx context.Context, githubClient *github.Client, boshPackageVersion *boshPackageVersion) *githubVersion {
-	return &githubVersion{
-		githubClient:          githubClient,
-		boshPackageVersion:    boshPackageVersion,
-		firstReleasedVersions: map[string]VersionInfo{},
-		ctx:                   ctx,
+func NewGithubVersion(ctx context.Context, githubClient *github.Client, boshPackageVersion *boshPackageVersion, cache *persistentCache) *githubVersion {
+	f := &githubVersion{
+		githubClient:       githubClient,
+		boshPackageVersion: boshPackageVersion,
+		cache:              cache,
+		ctx:                ctx,
+		subject:            "golang package",
+		releaseLists:       newMemo[[]*github.RepositoryRelease](MEMO_TTL),
+		releaseHistories:   newReleaseHistories(),
+		packageListings:    newMemo[[]*github.RepositoryContent](MEMO_TTL),
+		goModListings:      newMemo[[]*github.TreeEntry](MEMO_TTL),
+		searchFanout:       1,
 	}
+	f.refVersion = f.getGolangVersionOnRef
+	return f
 }
 
-func (f *githubVersion) GetDevelopVersion(release config.Release) (string, error) {
-	return f.getGolangVersionOnRef(release, "develop")
+func (f *githubVersion) GetDevelopVersion(release config.Release) (DevelopVersionInfo, error) {
+	result, err := f.refVersion(release, release.DevelopBranch)
+	if err != nil {
+		return DevelopVersionInfo{}, err
+	}
+	return DevelopVersionInfo{GolangVersion: result.Version, FromBlobs: result.FromBlobs}, nil
 }
 
-func (f *githubVersion) GetReleasedVersion(release config.Release) (string, error) {
-	publishedReleases, _, err := f.githubClient.Repositories.ListReleases(f.ctx, release.Owner, release.Repo, &github.ListOptions{PerPage: 1})
+func (f *githubVersion) GetReleasedVersion(release config.Release) (ReleasedVersionInfo, error) {
+	publishedReleases, err := f.listAllReleases(release)
 	if err != nil {
-		return "", err
+		return ReleasedVersionInfo{}, err
 	}
 	if len(publishedReleases) < 1 {
-		return "", errors.New("no results for published releases")
-
+		return ReleasedVersionInfo{}, errors.New("no results for published releases")
+	}
+	selected, err := SelectRelease(release, publishedReleases)
+	if err != nil {
+		return ReleasedVersionInfo{}, err
+	}
+	result, err := f.getGolangVersionOnTag(release, selected.Tag)
+	if err != nil {
+		return ReleasedVersionInfo{}, err
 	}
-	return f.getGolangVersionOnRef(release, publishedReleases[0].GetTagName())
+	return ReleasedVersionInfo{
+		GolangVersion: result.Version,
+		FromBlobs:     result.FromBlobs,
+		Tag:           selected.Tag,
+		Reason:        selected.Reason,
+		stable:        StableReleases(release, publishedReleases),
+	}, nil
 }
 
-func (f *githubVersion) GetFirstReleasedVersion(release config.Release, releasedVersion string) (VersionInfo, error) {
-	releasedVersionMajorMinor := MajorMinor(releasedVersion)
-	if versionInfo, ok := f.firstReleasedVersions[releaseVersionKey(release.Name, releasedVersionMajorMinor)]; ok {
+// GetFirstReleasedVersion finds the first release that ships the golang minor
+// of the released version and the first release that ships its exact patch.
+// Golang versions only move forward across semver-sorted releases, so both
+// are found with a binary search over the release history.
+func (f *githubVersion) GetFirstReleasedVersion(release config.Release, releasedVersion ReleasedVersionInfo) (VersionInfo, error) {
+	cacheKey := fmt.Sprintf("%sfirst-released/%s/%s/%s/%s", f.cachePrefix, release.Owner, release.Repo, release.Platform, releasedVersion.Tag)
+	var versionInfo VersionInfo
+	if f.cache.Get(cacheKey, &versionInfo) {
 		return versionInfo, nil
 	}
-	publishedReleases, _, err := f.githubClient.Repositories.ListReleases(f.ctx, release.Owner, release.Repo, &github.ListOptions{PerPage: 20})
+
+	stable := releasedVersion.stable
+	if stable == nil {
+		publishedReleases, err := f.listAllReleases(release)
+		if err != nil {
+			return VersionInfo{}, err
+		}
+		stable = StableReleases(release, publishedReleases)
+	}
+	releasedIndex := -1
+	for i, stableRelease := range stable {
+		if stableRelease.Tag == releasedVersion.Tag {
+			releasedIndex = i
+		}
+	}
+	if releasedIndex < 0 {
+		return VersionInfo{}, fmt.Errorf("released tag %s not found in release history", releasedVersion.Tag)
+	}
+	stable = stable[:releasedIndex+1]
+
+	releasedGolangV, err := semver.NewVersion(releasedVersion.GolangVersion)
 	if err != nil {
 		return VersionInfo{}, err
 	}
-	if len(publishedReleases) < 1 {
-		return VersionInfo{}, errors.New("no results for published releases")
+	minorV, err := semver.NewVersion(MajorMinor(releasedVersion.GolangVersion))
+	if err != nil {
+		return VersionInfo{}, err
 	}
 
-	versionInfo := VersionInfo{}
-	for _, publishedRelease := range publishedReleases {
-		golangVersion, err := f.getGolangVersionOnRef(release, publishedRelease.GetTagName())
-		if err != nil {
-			if _, ok := err.(NotFoundError); ok {
-				f.firstReleasedVersions[releaseVersionKey(release.Name, releasedVersionMajorMinor)] = versionInfo
-				return versionInfo, nil
+	minorIndex, minorGolangVersion, err := f.firstReleaseWith(release, stable, minorV)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	patchIndex, patchGolangVersion, err := f.firstReleaseWith(release, stable[minorIndex:], releasedGolangV)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	patchIndex += minorIndex
+
+	versionInfo = VersionInfo{
+		GolangVersion:       minorGolangVersion,
+		ReleaseVersion:      releaseVersionName(stable[minorIndex]),
+		Tag:                 stable[minorIndex].Tag,
+		PublishedAt:         stable[minorIndex].PublishedAt,
+		PatchGolangVersion:  patchGolangVersion,
+		PatchReleaseVersion: releaseVersionName(stable[patchIndex]),
+		PatchTag:            stable[patchIndex].Tag,
+		PatchPublishedAt:    stable[patchIndex].PublishedAt,
+	}
+	err = f.cache.Set(cacheKey, versionInfo)
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	return versionInfo, nil
+}
+
+// firstReleaseWith returns the index of the first release whose golang
+// version is at least minV. The last release must satisfy it. Releases
+// without a golang package are treated as older. Each round reads
+// searchFanout evenly spaced releases, which is a binary search for a
+// fanout of one.
+func (f *githubVersion) firstReleaseWith(release config.Release, stable []SelectedRelease, minV *semver.Version) (int, string, error) {
+	lo, hi := 0, len(stable)-1
+	result, err := f.getGolangVersionOnTag(release, stable[hi].Tag)
+	if err != nil {
+		return 0, "", err
+	}
+	golangVersion := result.Version
+	for lo < hi {
+		candidates := searchCandidates(lo, hi, f.searchFanout)
+		if f.prefetchTags != nil {
+			var tags []string
+			for _, candidate := range candidates {
+				tags = append(tags, stable[candidate].Tag)
 			}
-			return VersionInfo{}, err
+			f.prefetchTags(release, tags)
+		}
+		nextLo := lo
+		for _, candidate := range candidates {
+			candidateResult, err := f.getGolangVersionOnTag(release, stable[candidate].Tag)
+			candidateGolangVersion := candidateResult.Version
+			if err != nil {
+				if _, ok := err.(NotFoundError); ok {
+					nextLo = candidate + 1
+					continue
+				}
+				return 0, "", err
+			}
+			candidateGolangV, err := semver.NewVersion(candidateGolangVersion)
+			if err != nil {
+				return 0, "", err
+			}
+			if candidateGolangV.LessThan(minV) {
+				nextLo = candidate + 1
+			} else {
+				hi = candidate
+				golangVersion = candidateGolangVersion
+				break
+			}
+		}
+		lo = nextLo
+	}
+	return hi, golangVersion, nil
+}
+
+// searchCandidates returns up to fanout ascending indexes in [lo, hi) that
+// split the range evenly.
+func searchCandidates(lo int, hi int, fanout int) []int {
+	if fanout < 1 {
+		fanout = 1
+	}
+	var candidates []int
+	for i := 1; i <= fanout; i++ {
+		candidate := lo + i*(hi-lo)/(fanout+1)
+		if candidate >= hi {
+			break
 		}
-		if MajorMinor(golangVersion) == releasedVersionMajorMinor {
-			versionInfo.ReleaseVersion = publishedRelease.GetName()
-			versionInfo.GolangVersion = golangVersion
-		} else {
-			f.firstReleasedVersions[releaseVersionKey(release.Name, releasedVersionMajorMinor)] = versionInfo
-			return versionInfo, nil
+		if len(candidates) == 0 || candidates[len(candidates)-1] < candidate {
+			candidates = append(candidates, candidate)
 		}
 	}
-	return VersionInfo{}, errors.New("failed to find first min version")
+	return candidates
 }
 
-func (f *githubVersion) getGolangVersionOnRef(release config.Release, ref string) (string, error) {
-	_, packagesDirContent, response, err := f.githubClient.Repositories.GetContents(f.ctx, release.Owner, release.Repo, "packages", &github.RepositoryContentGetOptions{Ref: ref})
+// listAllReleases returns the full release history of the repository, only
+// listing the releases created since the previous call. The platforms and
+// release lines of a repository share the result.
+func (f *githubVersion) listAllReleases(release config.Release) ([]*github.RepositoryRelease, error) {
+	memoKey := release.Owner + "/" + release.Repo
+	if result, ok := f.releaseLists.get(memoKey); ok {
+		return result, nil
+	}
+	result, err := f.releaseHistories.list(f.ctx, f.githubClient, release.Owner, release.Repo)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	f.releaseLists.set(memoKey, result)
+	return result, nil
+}
+
+// getGolangVersionOnTag caches the golang version on a tag, since tags do not
+// move.
+func (f *githubVersion) getGolangVersionOnTag(release config.Release, tag string) (golangVersionResult, error) {
+	cacheKey := f.tagCacheKey(release, tag)
+	var cached tagGolangVersion
+	if f.cache.Get(cacheKey, &cached) {
+		if cached.NotFound {
+			return golangVersionResult{}, NotFoundError{fmt.Errorf("%s not found for release %s on %s", f.subject, release.Name, tag)}
+		}
+		return golangVersionResult{Version: cached.GolangVersion, FromBlobs: cached.FromBlobs}, nil
+	}
+
+	result, err := f.refVersion(release, tag)
+	if err != nil {
+		if _, ok := err.(NotFoundError); ok {
+			if cacheErr := f.cache.Set(cacheKey, tagGolangVersion{NotFound: true}); cacheErr != nil {
+				return golangVersionResult{}, cacheErr
+			}
+		}
+		return golangVersionResult{}, err
+	}
+	err = f.cache.Set(cacheKey, tagGolangVersion{GolangVersion: result.Version, FromBlobs: result.FromBlobs})
+	if err != nil {
+		return golangVersionResult{}, err
+	}
+	return result, nil
+}
+
+func (f *githubVersion) tagCacheKey(release config.Release, tag string) string {
+	return fmt.Sprintf("%sgolang-on-tag/%s/%s/%s/%s", f.cachePrefix, release.Owner, release.Repo, release.Platform, tag)
+}
+
+// isTagCached reports whether the golang version on a tag is known without
+// reading the tag.
+func (f *githubVersion) isTagCached(release config.Release, tag string) bool {
+	var cached tagGolangVersion
+	return f.cache.Get(f.tagCacheKey(release, tag), &cached)
+}
+
+func (f *githubVersion) getGolangVersionOnRef(release config.Release, ref string) (golangVersionResult, error) {
+	packagesDirContent, err := f.getPackagesListing(release, ref)
+	if _, ok := err.(NotFoundError); ok {
+		return f.getBlobGolangVersion(release, ref)
+	}
+	if err != nil {
+		return golangVersionResult{}, err
 	}
 
 	golangPackageName, found := findGolangPackageName(packagesDirContent, release.Platform)
 	if !found {
-		return "", NotFoundError{fmt.Errorf("golang package not found for release: %s", release.Name)}
+		return f.getBlobGolangVersion(release, ref)
 	}
 	specContent, _, response, err := f.githubClient.Repositories.GetContents(f.ctx, release.Owner, release.Repo, fmt.Sprintf("packages/%s/spec.lock", golangPackageName), &github.RepositoryContentGetOptions{Ref: ref})
 	if err != nil {
 		if response.StatusCode == http.StatusNotFound {
-			return "", NotFoundError{err}
+			return golangVersionResult{}, NotFoundError{err}
 		}
-		return "", err
+		return golangVersionResult{}, err
 	}
 	spec, err := specContent.GetContent()
 	if err != nil {
-		return "", err
+		return golangVersionResult{}, err
 	}
 
 	var packageSpec PackageSpec
 	err = yaml.Unmarshal([]byte(spec), &packageSpec)
 	if err != nil {
-		return "", err
+		return golangVersionResult{}, err
 	}
 
-	return f.boshPackageVersion.GetFingerprintVersion(packageSpec.Fingerprint, golangPackageName)
+	return vendoredGolangVersion(f.boshPackageVersion.GetFingerprintVersion(packageSpec.Fingerprint, golangPackageName))
 }
 
-func releaseVersionKey(releaseName string, version string) string {
-	return fmt.Sprintf("%s-%s", releaseName, version)
+func vendoredGolangVersion(golangVersion string, err error) (golangVersionResult, error) {
+	if err != nil {
+		return golangVersionResult{}, err
+	}
+	return golangVersionResult{Version: golangVersion}, nil
+}
+
+// getPackagesListing lists the packages of a ref once for all platforms.
+func (f *githubVersion) getPackagesListing(release config.Release, ref string) ([]*github.RepositoryContent, error) {
+	memoKey := fmt.Sprintf("%s/%s/%s", release.Owner, release.Repo, ref)
+	if listing, ok := f.packageListings.get(memoKey); ok {
+		return listing, nil
+	}
+	_, listing, response, err := f.githubClient.Repositories.GetContents(f.ctx, release.Owner, release.Repo, "packages", &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return nil, NotFoundError{err}
+		}
+		return nil, err
+	}
+	f.packageListings.set(memoKey, listing)
+	return listing, nil
+}
+
+func releaseVersionName(release SelectedRelease) string {
+	if release.Name != "" {
+		return release.Name
+	}
+	return release.Tag
 }
 
 func findGolangPackageName(directoryContent []*github.RepositoryContent, platform string) (string, bool) {
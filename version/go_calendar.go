@@ -0,0 +1,108 @@
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	CALENDAR_DATE_FORMAT = "2006-01-02"
+)
+
+type GoMinorRelease struct {
+	Version  string `json:"version"`
+	Released string `json:"released"`
+}
+
+// GoMinor is a Go minor release. Go supports the two most recent minors, so
+// a minor reaches its end of support when the second minor after it is
+// released. EndOfSupport is zero while that has not been scheduled.
+type GoMinor struct {
+	Version      string
+	Released     time.Time
+	EndOfSupport time.Time
+	version      *semver.Version
+}
+
+// goCalendar logs once per minor that is looked up but missing, since that
+// means the calendar is out of date.
+type goCalendar struct {
+	minors  []GoMinor
+	missing map[string]bool
+	mux     sync.Mutex
+}
+
+// LoadGoCalendar reads a JSON list of Go minor releases and their release
+// dates.
+func LoadGoCalendar(path string) (*goCalendar, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var releases []GoMinorRelease
+	err = json.Unmarshal(content, &releases)
+	if err != nil {
+		return nil, err
+	}
+
+	var minors []GoMinor
+	for _, release := range releases {
+		minorV, err := semver.NewVersion(release.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse go calendar version %s: %w", release.Version, err)
+		}
+		released, err := time.Parse(CALENDAR_DATE_FORMAT, release.Released)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse release date of go %s: %w", release.Version, err)
+		}
+		minors = append(minors, GoMinor{Version: release.Version, Released: released, version: minorV})
+	}
+	sort.Slice(minors, func(i, j int) bool {
+		return minors[i].version.LessThan(minors[j].version)
+	})
+	for i := range minors {
+		if i+2 < len(minors) {
+			minors[i].EndOfSupport = minors[i+2].Released
+		}
+	}
+	return &goCalendar{minors: minors, missing: map[string]bool{}}, nil
+}
+
+// Minor returns the minor of a golang version, such as 1.22 for 1.22.3.
+func (c *goCalendar) Minor(golangVersion string) (GoMinor, bool) {
+	if len(strings.Split(golangVersion, ".")) < 2 {
+		return GoMinor{}, false
+	}
+	minorVersion := MajorMinor(golangVersion)
+	for _, minor := range c.minors {
+		if minor.Version == minorVersion {
+			return minor, true
+		}
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if !c.missing[minorVersion] {
+		c.missing[minorVersion] = true
+		log.Printf("go release calendar has no entry for go %s, update golang_releases.json or GO_RELEASE_CALENDAR", minorVersion)
+	}
+	return GoMinor{}, false
+}
+
+// Minors returns the minors released by now, newest first.
+func (c *goCalendar) Minors(now time.Time) []GoMinor {
+	var minors []GoMinor
+	for i := len(c.minors) - 1; i >= 0; i-- {
+		if !c.minors[i].Released.After(now) {
+			minors = append(minors, c.minors[i])
+		}
+	}
+	return minors
+}
@@ -0,0 +1,53 @@
+package version
+
+import (
+	"strings"
+)
+
+// GoMod holds the parts of a go.mod file that are tracked.
+type GoMod struct {
+	Go        string
+	Toolchain string
+	Requires  map[string]string
+}
+
+// ParseGoMod reads the go and toolchain directives and the required module
+// versions. Replace and exclude directives are ignored.
+func ParseGoMod(content string) GoMod {
+	goMod := GoMod{Requires: map[string]string{}}
+	inRequireBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if inRequireBlock {
+			if fields[0] == ")" {
+				inRequireBlock = false
+			} else if len(fields) >= 2 {
+				goMod.Requires[fields[0]] = fields[1]
+			}
+			continue
+		}
+		switch fields[0] {
+		case "go":
+			if len(fields) >= 2 {
+				goMod.Go = fields[1]
+			}
+		case "toolchain":
+			if len(fields) >= 2 {
+				goMod.Toolchain = strings.TrimPrefix(fields[1], "go")
+			}
+		case "require":
+			if len(fields) >= 2 && fields[1] == "(" {
+				inRequireBlock = true
+			} else if len(fields) >= 3 {
+				goMod.Requires[fields[1]] = fields[2]
+			}
+		}
+	}
+	return goMod
+}
@@ -0,0 +1,125 @@
+package version
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+)
+
+var goVersionRegexp = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?(?:(rc|beta)(\d+))?$`)
+
+// GoModMismatch is a go or toolchain directive of a go.mod that asks for a
+// newer golang than the release packages. A newer go directive breaks the
+// build, a newer toolchain makes the go command download that toolchain.
+type GoModMismatch struct {
+	Ref       string
+	Path      string
+	Directive string
+	Version   string
+	Packaged  string
+}
+
+func (m GoModMismatch) String() string {
+	return fmt.Sprintf("%s on %s declares %s %s, newer than the packaged golang %s", m.Path, m.Ref, m.Directive, m.Version, m.Packaged)
+}
+
+type goModDirectives struct {
+	Go        string `json:"go"`
+	Toolchain string `json:"toolchain"`
+}
+
+// GetDevelopGoModMismatches checks the go.mod files on the development branch
+// against the golang version packaged there.
+func (f *githubVersion) GetDevelopGoModMismatches(release config.Release, golangVersion string) ([]GoModMismatch, error) {
+	return f.getGoModMismatches(release, release.DevelopBranch, golangVersion)
+}
+
+// GetReleasedGoModMismatches checks the go.mod files on the released tag
+// against the golang version packaged in that release.
+func (f *githubVersion) GetReleasedGoModMismatches(release config.Release, releasedVersion ReleasedVersionInfo) ([]GoModMismatch, error) {
+	return f.getGoModMismatches(release, releasedVersion.Tag, releasedVersion.GolangVersion)
+}
+
+func (f *githubVersion) getGoModMismatches(release config.Release, ref string, golangVersion string) ([]GoModMismatch, error) {
+	packagedV, err := parseGoVersion(golangVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse packaged golang version %s: %w", golangVersion, err)
+	}
+	goModFiles, err := f.listGoModFiles(release, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches []GoModMismatch
+	for _, goModFile := range goModFiles {
+		directives, err := f.getGoModDirectives(release, goModFile.GetSHA())
+		if err != nil {
+			return nil, err
+		}
+		for _, directive := range []struct{ name, version string }{
+			{"go", directives.Go},
+			{"toolchain", directives.Toolchain},
+		} {
+			if directive.version == "" {
+				continue
+			}
+			directiveV, err := parseGoVersion(directive.version)
+			if err != nil {
+				log.Printf("skipping %s directive %s in %s of %s on %s: %s", directive.name, directive.version, goModFile.GetPath(), release.Name, ref, err.Error())
+				continue
+			}
+			if directiveV.GreaterThan(packagedV) {
+				mismatches = append(mismatches, GoModMismatch{
+					Ref:       ref,
+					Path:      goModFile.GetPath(),
+					Directive: directive.name,
+					Version:   directive.version,
+					Packaged:  golangVersion,
+				})
+			}
+		}
+	}
+	return mismatches, nil
+}
+
+// getGoModDirectives caches the directives of a go.mod by its blob SHA, since
+// blobs do not change.
+func (f *githubVersion) getGoModDirectives(release config.Release, sha string) (goModDirectives, error) {
+	cacheKey := "go-mod-directives/" + sha
+	var directives goModDirectives
+	if f.cache.Get(cacheKey, &directives) {
+		return directives, nil
+	}
+	content, _, err := f.githubClient.Git.GetBlobRaw(f.ctx, release.Owner, release.Repo, sha)
+	if err != nil {
+		return goModDirectives{}, err
+	}
+	goMod := ParseGoMod(string(content))
+	directives = goModDirectives{Go: goMod.Go, Toolchain: goMod.Toolchain}
+	err = f.cache.Set(cacheKey, directives)
+	if err != nil {
+		return goModDirectives{}, err
+	}
+	return directives, nil
+}
+
+// parseGoVersion reads golang versions such as 1.21, 1.21.5 and 1.22rc1.
+// A version without a patch is taken as the .0 release.
+func parseGoVersion(golangVersion string) (*semver.Version, error) {
+	matches := goVersionRegexp.FindStringSubmatch(golangVersion)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid golang version %q", golangVersion)
+	}
+	patch := matches[3]
+	if patch == "" {
+		patch = "0"
+	}
+	normalized := fmt.Sprintf("%s.%s.%s", matches[1], matches[2], patch)
+	if matches[4] != "" {
+		normalized += "-" + matches[4] + "." + matches[5]
+	}
+	return semver.NewVersion(normalized)
+}
@@ -0,0 +1,103 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/google/go-github/v54/github"
+)
+
+// NewGoModuleVersion tracks the version of a Go module required by the
+// go.mod files under src/ instead of the packaged golang version.
+func NewGoModuleVersion(ctx context.Context, githubClient *github.Client, cache *persistentCache, module string) *githubVersion {
+	f := &githubVersion{
+		githubClient:     githubClient,
+		cache:            cache,
+		ctx:              ctx,
+		subject:          "module " + module,
+		cachePrefix:      "module/" + module + "/",
+		releaseLists:     newMemo[[]*github.RepositoryRelease](MEMO_TTL),
+		releaseHistories: newReleaseHistories(),
+		goModListings:    newMemo[[]*github.TreeEntry](MEMO_TTL),
+	}
+	f.refVersion = func(release config.Release, ref string) (golangVersionResult, error) {
+		return vendoredGolangVersion(f.getModuleVersionOnRef(release, ref, module))
+	}
+	return f
+}
+
+// getModuleVersionOnRef returns the lowest version of the module required
+// by any go.mod under src/, since that go.mod is the one still to be bumped.
+func (f *githubVersion) getModuleVersionOnRef(release config.Release, ref string, module string) (string, error) {
+	goModFiles, err := f.listGoModFiles(release, ref)
+	if err != nil {
+		return "", err
+	}
+
+	var lowest *semver.Version
+	var lowestVersion string
+	for _, goModFile := range goModFiles {
+		content, _, err := f.githubClient.Git.GetBlobRaw(f.ctx, release.Owner, release.Repo, goModFile.GetSHA())
+		if err != nil {
+			return "", err
+		}
+		moduleVersion, found := ParseGoMod(string(content)).Requires[module]
+		if !found {
+			continue
+		}
+		moduleV, err := semver.NewVersion(moduleVersion)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s version %s in %s: %w", module, moduleVersion, goModFile.GetPath(), err)
+		}
+		if lowest == nil || moduleV.LessThan(lowest) {
+			lowest = moduleV
+			lowestVersion = strings.TrimPrefix(moduleVersion, "v")
+		}
+	}
+	if lowest == nil {
+		return "", NotFoundError{fmt.Errorf("module %s not required by release %s on %s", module, release.Name, ref)}
+	}
+	return lowestVersion, nil
+}
+
+// listGoModFiles lists the go.mod files under src/ on a ref, skipping
+// vendored modules. Submodules are not listed by the tree and are logged.
+func (f *githubVersion) listGoModFiles(release config.Release, ref string) ([]*github.TreeEntry, error) {
+	memoKey := fmt.Sprintf("%s/%s/%s", release.Owner, release.Repo, ref)
+	if goModFiles, ok := f.goModListings.get(memoKey); ok {
+		return goModFiles, nil
+	}
+	tree, _, err := f.githubClient.Git.GetTree(f.ctx, release.Owner, release.Repo, ref, true)
+	if err != nil {
+		return nil, err
+	}
+	if tree.GetTruncated() {
+		log.Printf("tree of %s on %s is truncated, some go.mod files may be missed", release.Name, ref)
+	}
+
+	var goModFiles []*github.TreeEntry
+	var submodules []string
+	for _, entry := range tree.Entries {
+		path := entry.GetPath()
+		if entry.GetType() == "commit" && strings.HasPrefix(path, "src/") {
+			submodules = append(submodules, path)
+			continue
+		}
+		if entry.GetType() != "blob" || !strings.HasPrefix(path, "src/") || !strings.HasSuffix(path, "/go.mod") {
+			continue
+		}
+		if strings.Contains(path, "/vendor/") {
+			continue
+		}
+		goModFiles = append(goModFiles, entry)
+	}
+	if len(submodules) > 0 {
+		log.Printf("tree of %s on %s has submodules under src/, go.mod files in %s are missed", release.Name, ref, strings.Join(submodules, ", "))
+	}
+	f.goModListings.set(memoKey, goModFiles)
+	return goModFiles, nil
+}
@@ -0,0 +1,85 @@
+package version
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	GITHUB_GRAPHQL_URL = "https://api.github.com/graphql"
+)
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphqlError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path"`
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphqlError  `json:"errors"`
+}
+
+// graphqlClient posts queries to the GitHub GraphQL API. The http client is
+// expected to add authentication.
+type graphqlClient struct {
+	httpClient *http.Client
+	url        string
+}
+
+func newGraphqlClient(httpClient *http.Client, url string) *graphqlClient {
+	return &graphqlClient{
+		httpClient: httpClient,
+		url:        url,
+	}
+}
+
+// Query decodes the data of the response into out. Errors that only affect
+// some fields, such as a missing repository, are returned as partial errors
+// alongside the data that could be read.
+func (c *graphqlClient) Query(ctx context.Context, query string, variables map[string]interface{}, out interface{}) ([]string, error) {
+	body, err := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", c.url, res.StatusCode)
+	}
+
+	var response graphqlResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	var partialErrors []string
+	for _, graphqlErr := range response.Errors {
+		partialErrors = append(partialErrors, fmt.Sprintf("%s: %s", strings.Join(graphqlErr.Path, "."), graphqlErr.Message))
+	}
+	if len(response.Data) == 0 || string(response.Data) == "null" {
+		return nil, fmt.Errorf("graphql query failed: %s", strings.Join(partialErrors, "; "))
+	}
+	err = json.Unmarshal(response.Data, out)
+	if err != nil {
+		return nil, err
+	}
+	return partialErrors, nil
+}
@@ -0,0 +1,290 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/google/go-github/v54/github"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	GRAPHQL_BATCH_SIZE = 50
+	PREFETCH_TTL       = 30 * time.Second
+	// SEARCH_FANOUT is how many tags of a release history search are read
+	// per round, with one batch of GraphQL queries.
+	SEARCH_FANOUT = 15
+)
+
+// RefRequest names a ref of a release whose golang version should be
+// prefetched. Tags do not move, so what was prefetched for them does not
+// expire until it is read.
+type RefRequest struct {
+	Release config.Release
+	Ref     string
+	Tag     bool
+}
+
+type prefetchedSpec struct {
+	golangPackage string
+	fingerprint   string
+	notFound      bool
+	tag           bool
+	at            time.Time
+}
+
+type graphqlObject struct {
+	Entries []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"entries"`
+	Text *string `json:"text"`
+}
+
+type graphqlRepository struct {
+	Object *graphqlObject `json:"object"`
+}
+
+// graphqlVersion is a githubVersion that reads the packages tree and the
+// golang spec.lock of many releases and refs with two batched GraphQL
+// queries instead of two REST calls per ref. That covers the development
+// branches, the released tags and the tags read by the search for the first
+// release with a golang version. Refs that were not prefetched, or could not
+// be read with GraphQL, fall back to REST. Without an http client every ref
+// is read with REST.
+type graphqlVersion struct {
+	*githubVersion
+	client     *graphqlClient
+	prefetched map[string]prefetchedSpec
+	mux        sync.Mutex
+}
+
+func NewGraphqlVersion(ctx context.Context, githubClient *github.Client, httpClient *http.Client, boshPackageVersion *boshPackageVersion, cache *persistentCache) *graphqlVersion {
+	v := &graphqlVersion{
+		githubVersion: NewGithubVersion(ctx, githubClient, boshPackageVersion, cache),
+		prefetched:    map[string]prefetchedSpec{},
+	}
+	if httpClient != nil {
+		v.client = newGraphqlClient(httpClient, GITHUB_GRAPHQL_URL)
+		v.githubVersion.searchFanout = SEARCH_FANOUT
+		v.githubVersion.prefetchTags = v.prefetchTags
+	}
+	v.githubVersion.refVersion = v.getGolangVersionOnRef
+	return v
+}
+
+// PrefetchDevelop prefetches the development branch of every release.
+func (v *graphqlVersion) PrefetchDevelop(releases []config.Release) error {
+	var requests []RefRequest
+	for _, release := range releases {
+		requests = append(requests, RefRequest{Release: release, Ref: release.DevelopBranch})
+	}
+	return v.Prefetch(requests)
+}
+
+// PrefetchReleased prefetches the released tag of every release whose
+// golang version on it is not cached yet.
+func (v *graphqlVersion) PrefetchReleased(releases []config.Release) error {
+	if v.client == nil {
+		return nil
+	}
+	var requests []RefRequest
+	for _, release := range releases {
+		if release.OnlyDevelop {
+			continue
+		}
+		publishedReleases, err := v.listAllReleases(release)
+		if err != nil {
+			log.Printf("failed to list releases of %s for prefetching: %s", release.Name, err.Error())
+			continue
+		}
+		selected, err := SelectRelease(release, publishedReleases)
+		if err != nil || v.isTagCached(release, selected.Tag) {
+			continue
+		}
+		requests = append(requests, RefRequest{Release: release, Ref: selected.Tag, Tag: true})
+	}
+	return v.Prefetch(requests)
+}
+
+// prefetchTags prefetches the tags of a release history search round that
+// are not cached yet.
+func (v *graphqlVersion) prefetchTags(release config.Release, tags []string) {
+	var requests []RefRequest
+	for _, tag := range tags {
+		if !v.isTagCached(release, tag) {
+			requests = append(requests, RefRequest{Release: release, Ref: tag, Tag: true})
+		}
+	}
+	if len(requests) < 2 {
+		return
+	}
+	err := v.Prefetch(requests)
+	if err != nil {
+		log.Printf("failed to prefetch tags of %s, falling back to REST: %s", release.Name, err.Error())
+	}
+}
+
+func (v *graphqlVersion) Prefetch(requests []RefRequest) error {
+	if v.client == nil {
+		return nil
+	}
+	for start := 0; start < len(requests); start += GRAPHQL_BATCH_SIZE {
+		end := start + GRAPHQL_BATCH_SIZE
+		if end > len(requests) {
+			end = len(requests)
+		}
+		err := v.prefetchBatch(requests[start:end])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefetchBatch reads the packages tree of every ref once, even when several
+// platforms of a release ask for it, and then the spec.lock of every
+// platform's golang package.
+func (v *graphqlVersion) prefetchBatch(requests []RefRequest) error {
+	var treeRequests []RefRequest
+	var expressions []string
+	treeIndexes := map[string]int{}
+	requestTrees := make([]int, len(requests))
+	for i, request := range requests {
+		treeKey := fmt.Sprintf("%s/%s/%s", request.Release.Owner, request.Release.Repo, request.Ref)
+		treeIndex, ok := treeIndexes[treeKey]
+		if !ok {
+			treeIndex = len(treeRequests)
+			treeIndexes[treeKey] = treeIndex
+			treeRequests = append(treeRequests, request)
+			expressions = append(expressions, request.Ref+":packages")
+		}
+		requestTrees[i] = treeIndex
+	}
+	trees, err := v.queryObjects(treeRequests, expressions, "... on Tree { entries { name type } }")
+	if err != nil {
+		return err
+	}
+
+	var specRequests []RefRequest
+	var specExpressions []string
+	var golangPackages []string
+	now := time.Now()
+	for i, request := range requests {
+		tree := trees[requestTrees[i]]
+		if tree == nil {
+			continue
+		}
+		golangPackage, found := findGolangPackageEntry(tree, request.Release.Platform)
+		if !found {
+			v.store(request, prefetchedSpec{notFound: true, at: now})
+			continue
+		}
+		specRequests = append(specRequests, request)
+		specExpressions = append(specExpressions, fmt.Sprintf("%s:packages/%s/spec.lock", request.Ref, golangPackage))
+		golangPackages = append(golangPackages, golangPackage)
+	}
+	if len(specRequests) == 0 {
+		return nil
+	}
+
+	blobs, err := v.queryObjects(specRequests, specExpressions, "... on Blob { text }")
+	if err != nil {
+		return err
+	}
+	for i, request := range specRequests {
+		blob := blobs[i]
+		if blob == nil || blob.Text == nil {
+			continue
+		}
+		var packageSpec PackageSpec
+		err = yaml.Unmarshal([]byte(*blob.Text), &packageSpec)
+		if err != nil {
+			log.Printf("failed to parse prefetched spec.lock of %s on %s: %s", request.Release.Name, request.Ref, err.Error())
+			continue
+		}
+		v.store(request, prefetchedSpec{golangPackage: golangPackages[i], fingerprint: packageSpec.Fingerprint, at: now})
+	}
+	return nil
+}
+
+// queryObjects reads one git object per request in a single query. Objects
+// that could not be read are nil.
+func (v *graphqlVersion) queryObjects(requests []RefRequest, expressions []string, selection string) ([]*graphqlObject, error) {
+	var query strings.Builder
+	var params []string
+	variables := map[string]interface{}{}
+	for i, request := range requests {
+		params = append(params, fmt.Sprintf("$o%d: String!, $n%d: String!, $e%d: String!", i, i, i))
+		variables[fmt.Sprintf("o%d", i)] = request.Release.Owner
+		variables[fmt.Sprintf("n%d", i)] = request.Release.Repo
+		variables[fmt.Sprintf("e%d", i)] = expressions[i]
+		fmt.Fprintf(&query, "r%d: repository(owner: $o%d, name: $n%d) { object(expression: $e%d) { %s } }\n", i, i, i, i, selection)
+	}
+
+	var data map[string]*graphqlRepository
+	partialErrors, err := v.client.Query(v.ctx, fmt.Sprintf("query(%s) {\n%s}", strings.Join(params, ", "), query.String()), variables, &data)
+	if err != nil {
+		return nil, err
+	}
+	for _, partialErr := range partialErrors {
+		log.Printf("graphql prefetch: %s", partialErr)
+	}
+
+	objects := make([]*graphqlObject, len(requests))
+	for i := range requests {
+		if repository := data[fmt.Sprintf("r%d", i)]; repository != nil {
+			objects[i] = repository.Object
+		}
+	}
+	return objects, nil
+}
+
+func (v *graphqlVersion) store(request RefRequest, spec prefetchedSpec) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	spec.tag = request.Tag
+	v.prefetched[prefetchKey(request.Release, request.Ref)] = spec
+}
+
+func (v *graphqlVersion) take(release config.Release, ref string) (prefetchedSpec, bool) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	key := prefetchKey(release, ref)
+	spec, ok := v.prefetched[key]
+	delete(v.prefetched, key)
+	if !ok || (!spec.tag && time.Since(spec.at) > PREFETCH_TTL) {
+		return prefetchedSpec{}, false
+	}
+	return spec, true
+}
+
+func (v *graphqlVersion) getGolangVersionOnRef(release config.Release, ref string) (golangVersionResult, error) {
+	spec, ok := v.take(release, ref)
+	if !ok {
+		return v.githubVersion.getGolangVersionOnRef(release, ref)
+	}
+	if spec.notFound {
+		return v.getBlobGolangVersion(release, ref)
+	}
+	return vendoredGolangVersion(v.boshPackageVersion.GetFingerprintVersion(spec.fingerprint, spec.golangPackage))
+}
+
+func prefetchKey(release config.Release, ref string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", release.Owner, release.Repo, release.Platform, ref)
+}
+
+func findGolangPackageEntry(tree *graphqlObject, platform string) (string, bool) {
+	for _, entry := range tree.Entries {
+		if entry.Type == "tree" && strings.HasPrefix(entry.Name, "golang-") && strings.HasSuffix(entry.Name, platform) {
+			return entry.Name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,49 @@
+package version
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	MEMO_TTL = 30 * time.Second
+)
+
+type memoEntry[T any] struct {
+	value T
+	at    time.Time
+}
+
+// memo remembers values for a short time, so that what the platforms of a
+// release share, such as the release list and the packages listing of a
+// ref, is fetched once per refresh.
+type memo[T any] struct {
+	ttl     time.Duration
+	entries map[string]memoEntry[T]
+	mux     sync.Mutex
+}
+
+func newMemo[T any](ttl time.Duration) *memo[T] {
+	return &memo[T]{
+		ttl:     ttl,
+		entries: map[string]memoEntry[T]{},
+	}
+}
+
+func (m *memo[T]) get(key string) (T, bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	entry, ok := m.entries[key]
+	if !ok || time.Since(entry.at) > m.ttl {
+		delete(m.entries, key)
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (m *memo[T]) set(key string, value T) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.entries[key] = memoEntry[T]{value: value, at: time.Now()}
+}
@@ -0,0 +1,127 @@
+package version
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	CACHE_FLUSH_INTERVAL = 30 * time.Second
+)
+
+// persistentCache stores immutable answers, such as the golang version on a
+// tag, as JSON. Without a path it only keeps entries in memory. Set only
+// marks the cache dirty, Flush writes it to disk.
+type persistentCache struct {
+	path     string
+	entries  map[string]json.RawMessage
+	dirty    bool
+	mux      sync.Mutex
+	flushMux sync.Mutex
+}
+
+func NewPersistentCache(path string) (*persistentCache, error) {
+	cache := &persistentCache{
+		path:    path,
+		entries: map[string]json.RawMessage{},
+	}
+	if path == "" {
+		return cache, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(content, &cache.entries)
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func (c *persistentCache) Get(key string, value interface{}) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(entry, value) == nil
+}
+
+func (c *persistentCache) Set(key string, value interface{}) error {
+	entry, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.entries[key] = entry
+	c.dirty = true
+	return nil
+}
+
+// Flush writes the entries to a temporary file and renames it over the cache
+// file, so that a crash while writing leaves the previous file intact. It
+// does nothing when no entry changed since the last flush.
+func (c *persistentCache) Flush() error {
+	if c.path == "" {
+		return nil
+	}
+	c.flushMux.Lock()
+	defer c.flushMux.Unlock()
+
+	c.mux.Lock()
+	if !c.dirty {
+		c.mux.Unlock()
+		return nil
+	}
+	content, err := json.Marshal(c.entries)
+	c.dirty = false
+	c.mux.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = c.write(content)
+	if err != nil {
+		c.mux.Lock()
+		c.dirty = true
+		c.mux.Unlock()
+	}
+	return err
+}
+
+func (c *persistentCache) write(content []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(content)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), c.path)
+}
+
+func (c *persistentCache) Len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.entries)
+}
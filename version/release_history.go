@@ -0,0 +1,95 @@
+package version
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v54/github"
+)
+
+const (
+	RELEASE_HISTORY_RELIST_INTERVAL = time.Hour
+)
+
+type releaseHistory struct {
+	releases []*github.RepositoryRelease
+	listedAt time.Time
+}
+
+// releaseHistories keeps the release history of every repository, so that a
+// refresh only lists the releases created since the previous one. Releases
+// listed first are the most recently created, so listing stops at the first
+// page with a known release. The full history is listed again every
+// RELEASE_HISTORY_RELIST_INTERVAL to pick up deleted releases and drafts
+// published after newer releases were created.
+type releaseHistories struct {
+	histories map[string]releaseHistory
+	mux       sync.Mutex
+}
+
+func newReleaseHistories() *releaseHistories {
+	return &releaseHistories{
+		histories: map[string]releaseHistory{},
+	}
+}
+
+func (h *releaseHistories) list(ctx context.Context, githubClient *github.Client, owner string, repo string) ([]*github.RepositoryRelease, error) {
+	key := owner + "/" + repo
+	h.mux.Lock()
+	history, ok := h.histories[key]
+	h.mux.Unlock()
+	if !ok || time.Since(history.listedAt) > RELEASE_HISTORY_RELIST_INTERVAL {
+		releases, err := ListAllReleases(ctx, githubClient, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		h.set(key, releaseHistory{releases: releases, listedAt: time.Now()})
+		return releases, nil
+	}
+
+	known := map[int64]bool{}
+	for _, publishedRelease := range history.releases {
+		known[publishedRelease.GetID()] = true
+	}
+	var listed []*github.RepositoryRelease
+	reachedKnown := false
+	opts := &github.ListOptions{PerPage: 100}
+	for !reachedKnown {
+		publishedReleases, response, err := githubClient.Repositories.ListReleases(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		listed = append(listed, publishedReleases...)
+		for _, publishedRelease := range publishedReleases {
+			reachedKnown = reachedKnown || known[publishedRelease.GetID()]
+		}
+		if response.NextPage == 0 {
+			// the whole history was listed
+			h.set(key, releaseHistory{releases: listed, listedAt: time.Now()})
+			return listed, nil
+		}
+		opts.Page = response.NextPage
+	}
+
+	// the releases listed again replace their known entries, which may have
+	// been edited since
+	relisted := map[int64]bool{}
+	for _, publishedRelease := range listed {
+		relisted[publishedRelease.GetID()] = true
+	}
+	releases := listed
+	for _, publishedRelease := range history.releases {
+		if !relisted[publishedRelease.GetID()] {
+			releases = append(releases, publishedRelease)
+		}
+	}
+	h.set(key, releaseHistory{releases: releases, listedAt: history.listedAt})
+	return releases, nil
+}
+
+func (h *releaseHistories) set(key string, history releaseHistory) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.histories[key] = history
+}
@@ -0,0 +1,147 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/config"
+	"github.com/google/go-github/v54/github"
+)
+
+type SelectedRelease struct {
+	Tag         string
+	Name        string
+	Version     *semver.Version
+	PublishedAt time.Time
+	Reason      string
+}
+
+type skippedReleases struct {
+	drafts      int
+	prereleases int
+	unparsable  int
+}
+
+func (s skippedReleases) String() string {
+	var skipped []string
+	if s.drafts > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d drafts", s.drafts))
+	}
+	if s.prereleases > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d prereleases", s.prereleases))
+	}
+	if s.unparsable > 0 {
+		skipped = append(skipped, fmt.Sprintf("%d non-semver tags", s.unparsable))
+	}
+	return strings.Join(skipped, ", ")
+}
+
+// ListAllReleases pages through the full release history of a repository.
+func ListAllReleases(ctx context.Context, githubClient *github.Client, owner string, repo string) ([]*github.RepositoryRelease, error) {
+	var result []*github.RepositoryRelease
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		publishedReleases, response, err := githubClient.Repositories.ListReleases(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, publishedReleases...)
+		if response.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+// StableReleases returns the published releases that belong to the release
+// line, sorted by ascending semver. Drafts and prereleases are skipped unless
+// the release allows them.
+func StableReleases(release config.Release, publishedReleases []*github.RepositoryRelease) []SelectedRelease {
+	stable, _ := stableReleases(release, publishedReleases)
+	return stable
+}
+
+// SelectRelease picks the highest semver release among published releases
+// that belong to the release line.
+func SelectRelease(release config.Release, publishedReleases []*github.RepositoryRelease) (SelectedRelease, error) {
+	stable, skipped := stableReleases(release, publishedReleases)
+	if len(stable) == 0 {
+		return SelectedRelease{}, fmt.Errorf("no %s with a semver tag found (skipped %s)", describeReleases(release), skipped)
+	}
+
+	selected := stable[len(stable)-1]
+	selected.Reason = fmt.Sprintf("highest of %d %s", len(stable), describeReleases(release))
+	if skipped != (skippedReleases{}) {
+		selected.Reason = fmt.Sprintf("%s, skipped %s", selected.Reason, skipped)
+	}
+	if len(publishedReleases) > 0 && publishedReleases[0].GetTagName() != selected.Tag {
+		selected.Reason = fmt.Sprintf("%s; most recently created is %s", selected.Reason, publishedReleases[0].GetTagName())
+	}
+	return selected, nil
+}
+
+// describeReleases names the releases stableReleases keeps for the release.
+func describeReleases(release config.Release) string {
+	switch {
+	case release.IncludePrereleases && release.IncludeDrafts:
+		return "releases including prereleases and drafts"
+	case release.IncludePrereleases:
+		return "releases including prereleases"
+	case release.IncludeDrafts:
+		return "releases including drafts"
+	}
+	return "stable releases"
+}
+
+func stableReleases(release config.Release, publishedReleases []*github.RepositoryRelease) ([]SelectedRelease, skippedReleases) {
+	var stable []SelectedRelease
+	var skipped skippedReleases
+
+	for _, publishedRelease := range publishedReleases {
+		tag := publishedRelease.GetTagName()
+		if !release.MatchesTag(tag) {
+			continue
+		}
+		if publishedRelease.GetDraft() && !release.IncludeDrafts {
+			skipped.drafts++
+			continue
+		}
+		releaseV, err := ParseReleaseTag(release, tag)
+		if err != nil {
+			skipped.unparsable++
+			continue
+		}
+		if (publishedRelease.GetPrerelease() || releaseV.Prerelease() != "") && !release.IncludePrereleases {
+			skipped.prereleases++
+			continue
+		}
+		stable = append(stable, SelectedRelease{
+			Tag:         tag,
+			Name:        publishedRelease.GetName(),
+			Version:     releaseV,
+			PublishedAt: publishedRelease.GetPublishedAt().Time,
+		})
+	}
+
+	sort.SliceStable(stable, func(i, j int) bool {
+		return stable[i].Version.LessThan(stable[j].Version)
+	})
+	return stable, skipped
+}
+
+// ParseReleaseTag parses a release tag as semver after removing the
+// configured tag prefix.
+func ParseReleaseTag(release config.Release, tag string) (*semver.Version, error) {
+	if release.TagPrefix != "" {
+		if !strings.HasPrefix(tag, release.TagPrefix) {
+			return nil, errors.New("tag does not have the configured prefix")
+		}
+		tag = strings.TrimPrefix(tag, release.TagPrefix)
+	}
+	return semver.NewVersion(tag)
+}
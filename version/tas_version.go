@@ -2,15 +2,23 @@ package version
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/go-github/v54/github"
 	"gopkg.in/yaml.v2"
 )
 
 const (
+	TAS_OWNER          = "pivotal"
+	TAS_REPO           = "tas"
 	TAS_RELEASES_FILE  = "tas/Kilnfile.lock"
 	TASW_RELEASES_FILE = "tasw/Kilnfile.lock"
 	IST_RELEASES_FILE  = "ist/Kilnfile.lock"
+	TAS_FETCH_INTERVAL = time.Minute
 )
 
 type KilnRelease struct {
@@ -22,42 +30,79 @@ type Kilnfile struct {
 	Releases []KilnRelease `yaml:"releases"`
 }
 
+// tasVersion is shared by every releases provider. Fetch reads the Kilnfiles
+// at most once per TAS_FETCH_INTERVAL unless invalidated, and the versions
+// are read under releasesMux while a fetch replaces them.
 type tasVersion struct {
 	githubClient *github.Client
 	tasReleases  map[string]string
 	taswReleases map[string]string
 	istReleases  map[string]string
+	releasesMux  sync.RWMutex
+	lastFetch    time.Time
+	lastRef      string
+	lastErr      error
+	invalidated  atomic.Bool
+	fetchMux     sync.Mutex
+	fileSHAs     map[string]string
+	changeURLs   map[string]string
+	changesMux   sync.Mutex
 	ctx          context.Context
 }
 
 func NewTasVersion(ctx context.Context, githubClient *github.Client) *tasVersion {
 	return &tasVersion{
 		githubClient: githubClient,
+		fileSHAs:     map[string]string{},
+		changeURLs:   map[string]string{},
 		ctx:          ctx,
 	}
 }
 
+// Fetch refreshes the tile release versions, unless they were fetched
+// recently, and returns the error of the last fetch. On failure the
+// previously fetched versions are kept.
 func (v *tasVersion) Fetch(ref string) error {
-	var err error
-	v.tasReleases, err = v.fetchForFile(ref, TAS_RELEASES_FILE)
+	v.fetchMux.Lock()
+	defer v.fetchMux.Unlock()
+	invalidated := v.invalidated.Swap(false)
+	if !invalidated && !v.lastFetch.IsZero() && v.lastRef == ref && time.Since(v.lastFetch) < TAS_FETCH_INTERVAL {
+		return v.lastErr
+	}
+	v.lastFetch = time.Now()
+	v.lastRef = ref
+	v.lastErr = v.fetch(ref)
+	return v.lastErr
+}
+
+// Invalidate makes the next Fetch read the Kilnfiles again.
+func (v *tasVersion) Invalidate() {
+	v.invalidated.Store(true)
+}
+
+func (v *tasVersion) fetch(ref string) error {
+	tasReleases, err := v.fetchForFile(ref, TAS_RELEASES_FILE)
 	if err != nil {
 		return err
 	}
 
-	v.taswReleases, err = v.fetchForFile(ref, TASW_RELEASES_FILE)
+	taswReleases, err := v.fetchForFile(ref, TASW_RELEASES_FILE)
 	if err != nil {
 		return err
 	}
 
-	v.istReleases, err = v.fetchForFile(ref, IST_RELEASES_FILE)
+	istReleases, err := v.fetchForFile(ref, IST_RELEASES_FILE)
 	if err != nil {
 		return err
 	}
+	v.releasesMux.Lock()
+	defer v.releasesMux.Unlock()
+	v.tasReleases, v.taswReleases, v.istReleases = tasReleases, taswReleases, istReleases
 	return nil
 }
 
 func (v *tasVersion) fetchForFile(ref string, fileName string) (map[string]string, error) {
-	kilnContents, _, _, err := v.githubClient.Repositories.GetContents(v.ctx, "pivotal", "tas", fileName, &github.RepositoryContentGetOptions{Ref: ref})
+	kilnContents, _, _, err := v.githubClient.Repositories.GetContents(v.ctx, TAS_OWNER, TAS_REPO, fileName, &github.RepositoryContentGetOptions{Ref: ref})
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +111,12 @@ func (v *tasVersion) fetchForFile(ref string, fileName string) (map[string]strin
 	if err != nil {
 		return nil, err
 	}
+	v.changesMux.Lock()
+	if v.fileSHAs[fileName] != kilnContents.GetSHA() {
+		v.fileSHAs[fileName] = kilnContents.GetSHA()
+		v.changeURLs[fileName] = v.getChangeURL(ref, fileName)
+	}
+	v.changesMux.Unlock()
 
 	var kilnFile Kilnfile
 	err = yaml.Unmarshal([]byte(kilnContent), &kilnFile)
@@ -81,17 +132,50 @@ func (v *tasVersion) fetchForFile(ref string, fileName string) (map[string]strin
 	return releases, nil
 }
 
+// getChangeURL links to the last commit that changed a Kilnfile.lock, or to
+// its history when the commit cannot be found.
+func (v *tasVersion) getChangeURL(ref string, fileName string) string {
+	historyURL := fmt.Sprintf("https://github.com/%s/%s/commits/%s/%s", TAS_OWNER, TAS_REPO, ref, fileName)
+	commits, _, err := v.githubClient.Repositories.ListCommits(v.ctx, TAS_OWNER, TAS_REPO, &github.CommitsListOptions{
+		SHA:         ref,
+		Path:        fileName,
+		ListOptions: github.ListOptions{PerPage: 1},
+	})
+	if err != nil {
+		log.Printf("failed to get last change of %s: %s", fileName, err.Error())
+		return historyURL
+	}
+	if len(commits) < 1 || commits[0].GetHTMLURL() == "" {
+		return historyURL
+	}
+	return commits[0].GetHTMLURL()
+}
+
+// GetKilnfileChangeURL links to the change of the Kilnfile.lock that the
+// current tile versions were read from.
+func (v *tasVersion) GetKilnfileChangeURL(fileName string) string {
+	v.changesMux.Lock()
+	defer v.changesMux.Unlock()
+	return v.changeURLs[fileName]
+}
+
 func (v *tasVersion) GetTasReleaseVersion(releaseName string) (string, bool) {
+	v.releasesMux.RLock()
+	defer v.releasesMux.RUnlock()
 	version, ok := v.tasReleases[releaseName]
 	return version, ok
 }
 
 func (v *tasVersion) GetTaswReleaseVersion(releaseName string) (string, bool) {
+	v.releasesMux.RLock()
+	defer v.releasesMux.RUnlock()
 	version, ok := v.taswReleases[releaseName]
 	return version, ok
 }
 
 func (v *tasVersion) GetIstReleaseVersion(releaseName string) (string, bool) {
+	v.releasesMux.RLock()
+	defer v.releasesMux.RUnlock()
 	version, ok := v.istReleases[releaseName]
 	return version, ok
 }
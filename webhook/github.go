@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/golang-bump-progress/artifact"
+	"github.com/cloudfoundry-incubator/golang-bump-progress/version"
+	"github.com/google/go-github/v54/github"
+)
+
+const (
+	MAX_PAYLOAD_SIZE = 25 * 1024 * 1024
+
+	FINAL_BUILDS_PREFIX = ".final_builds/packages/golang-"
+	KILNFILE_LOCK       = "Kilnfile.lock"
+)
+
+type rowInvalidator interface {
+	Invalidate(owner string, repo string) bool
+}
+
+type tileInvalidator interface {
+	InvalidateTiles()
+}
+
+type fingerprintUpdater interface {
+	UpdateFromCommits(commitSHAs []string) error
+}
+
+// githubReceiver handles GitHub webhooks. push, release and create events
+// invalidate the rows of the repository they come from in every provider
+// that supports it, pushes to the golang bosh release update the fingerprint
+// cache and pushes that change a tile Kilnfile.lock invalidate the tile
+// versions. refresh is called afterwards to refetch what was invalidated.
+type githubReceiver struct {
+	secret       []byte
+	providers    []artifact.Provider
+	fingerprints fingerprintUpdater
+	refresh      func()
+}
+
+func NewGithubReceiver(secret string, providers []artifact.Provider, fingerprints fingerprintUpdater, refresh func()) *githubReceiver {
+	return &githubReceiver{
+		secret:       []byte(secret),
+		providers:    providers,
+		fingerprints: fingerprints,
+		refresh:      refresh,
+	}
+}
+
+func (g *githubReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_PAYLOAD_SIZE)
+	payload, err := github.ValidatePayload(r, g.secret)
+	if err != nil {
+		log.Printf("rejected github webhook: %s", err.Error())
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	event, err := github.ParseWebHook(github.WebHookType(r), payload)
+	if err != nil {
+		log.Printf("failed to parse github webhook: %s", err.Error())
+		http.Error(w, "failed to parse event", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	go g.process(event)
+}
+
+// process runs after the response is sent, since GitHub gives up on slow
+// webhook deliveries.
+func (g *githubReceiver) process(event interface{}) {
+	var changed bool
+	switch e := event.(type) {
+	case *github.PushEvent:
+		changed = g.handlePush(e)
+	case *github.ReleaseEvent:
+		changed = g.invalidate(e.GetRepo().GetFullName())
+	case *github.CreateEvent:
+		changed = g.invalidate(e.GetRepo().GetFullName())
+	}
+	if changed {
+		g.refresh()
+	}
+}
+
+func (g *githubReceiver) handlePush(e *github.PushEvent) bool {
+	fullName := e.GetRepo().GetFullName()
+	changed := g.invalidate(fullName)
+
+	switch {
+	case strings.EqualFold(fullName, version.GOLANG_BOSH_RELEASE_OWNER+"/"+version.GOLANG_BOSH_RELEASE_REPO):
+		commitSHAs := commitsTouching(e.Commits, func(path string) bool {
+			return strings.HasPrefix(path, FINAL_BUILDS_PREFIX)
+		})
+		if len(commitSHAs) > 0 {
+			err := g.fingerprints.UpdateFromCommits(commitSHAs)
+			if err != nil {
+				log.Printf("failed to update fingerprint cache from push: %s", err.Error())
+			}
+			changed = true
+		}
+	case strings.EqualFold(fullName, version.TAS_OWNER+"/"+version.TAS_REPO):
+		commitSHAs := commitsTouching(e.Commits, func(path string) bool {
+			return strings.HasSuffix(path, KILNFILE_LOCK)
+		})
+		for _, provider := range g.providers {
+			if tiles, ok := provider.(tileInvalidator); ok && len(commitSHAs) > 0 {
+				tiles.InvalidateTiles()
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func (g *githubReceiver) invalidate(fullName string) bool {
+	owner, repo, found := strings.Cut(fullName, "/")
+	if !found {
+		return false
+	}
+	changed := false
+	for _, provider := range g.providers {
+		if invalidator, ok := provider.(rowInvalidator); ok && invalidator.Invalidate(owner, repo) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+func commitsTouching(commits []*github.HeadCommit, matches func(path string) bool) []string {
+	var commitSHAs []string
+	for _, commit := range commits {
+		if touches(commit, matches) {
+			commitSHAs = append(commitSHAs, commit.GetID())
+		}
+	}
+	return commitSHAs
+}
+
+func touches(commit *github.HeadCommit, matches func(path string) bool) bool {
+	for _, paths := range [][]string{commit.Added, commit.Modified, commit.Removed} {
+		for _, path := range paths {
+			if matches(path) {
+				return true
+			}
+		}
+	}
+	return false
+}
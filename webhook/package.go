@@ -0,0 +1 @@
+package webhook // import "github.com/cloudfoundry-incubator/golang-bump-progress/webhook"